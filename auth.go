@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+var ErrAPIKeyRequired = errors.New("an API key is required")
+var ErrPermissionDenied = errors.New("API key does not grant the permission")
+
+// Permission is something only callers holding a suitable API key may do.
+type Permission string
+
+// PermissionPeek allows looking at cards still in a deck without drawing them.
+const PermissionPeek Permission = "peek"
+
+var knownPermissions = map[Permission]bool{
+	PermissionPeek: true,
+}
+
+type apiKey struct {
+	key         string
+	permissions map[Permission]bool
+}
+
+// Authorizer checks the API key sent as "Authorization: Bearer <key>"
+// against the keys it was configured with.
+type Authorizer struct {
+	keys []apiKey
+}
+
+// NewAuthorizer parses comma separated keys, each followed by a colon and the
+// "|" separated permissions it grants, e.g. "dealer-key:peek,qa-key:peek".
+func NewAuthorizer(spec string) (*Authorizer, error) {
+	auth := &Authorizer{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, permissions, found := strings.Cut(entry, ":")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid API key entry %q, expected key:permission", entry)
+		}
+		granted := make(map[Permission]bool)
+		for _, p := range strings.Split(permissions, "|") {
+			permission := Permission(strings.TrimSpace(p))
+			if !knownPermissions[permission] {
+				return nil, fmt.Errorf("unknown permission %q", permission)
+			}
+			granted[permission] = true
+		}
+		auth.keys = append(auth.keys, apiKey{key: key, permissions: granted})
+	}
+	return auth, nil
+}
+
+// Allowed reports whether key grants permission.
+func (a *Authorizer) Allowed(key string, permission Permission) bool {
+	allowed := false
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(k.key), []byte(key)) == 1 && k.permissions[permission] {
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+// Granted reports whether the request carries a key that grants permission,
+// for endpoints that serve everyone but show more to privileged callers.
+func (a *Authorizer) Granted(context *gin.Context, permission Permission) bool {
+	key := requestKey(context)
+	return key != "" && a.Allowed(key, permission)
+}
+
+// Require rejects requests without a key with 401 and requests whose key does
+// not grant permission with 403.
+func (a *Authorizer) Require(permission Permission) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		key := requestKey(context)
+		if key == "" {
+			respondError(context, http.StatusUnauthorized, ErrAPIKeyRequired)
+			return
+		}
+		if !a.Allowed(key, permission) {
+			respondError(context, http.StatusForbidden, fmt.Errorf("%w %s", ErrPermissionDenied, permission))
+			return
+		}
+		context.Next()
+	}
+}
+
+func requestKey(context *gin.Context) string {
+	return strings.TrimPrefix(context.GetHeader("Authorization"), "Bearer ")
+}
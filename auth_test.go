@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestAuthorizer(t *testing.T) {
+	t.Run("Parse API keys", func(t *testing.T) {
+		auth, err := NewAuthorizer(" dealer-key:peek , qa-key:peek")
+		if err != nil {
+			t.Fatalf("Failed to parse API keys: %v", err)
+		}
+		for _, key := range []string{"dealer-key", "qa-key"} {
+			if !auth.Allowed(key, PermissionPeek) {
+				t.Errorf("Key %q should grant %v", key, PermissionPeek)
+			}
+		}
+		if auth.Allowed("dealer", PermissionPeek) || auth.Allowed("", PermissionPeek) {
+			t.Error("Only configured keys should be allowed")
+		}
+	})
+	t.Run("Reject invalid API keys", func(t *testing.T) {
+		for _, spec := range []string{"dealer-key", ":peek", "dealer-key:deal", "dealer-key:"} {
+			if _, err := NewAuthorizer(spec); err == nil {
+				t.Errorf("An error is expected to return for %q", spec)
+			}
+		}
+	})
+	t.Run("Empty spec allows nothing", func(t *testing.T) {
+		auth, err := NewAuthorizer("")
+		if err != nil || auth.Allowed("", PermissionPeek) {
+			t.Errorf("An empty spec should parse and allow nothing, error: %v", err)
+		}
+	})
+}
@@ -1,7 +1,165 @@
 package main
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 type Card struct {
 	Value string `json:"value"`
 	Suit  string `json:"suit"`
 	Code  string `json:"code"`
+	// Copy tells apart identical cards in a multi-deck shoe, starting at 1.
+	// It is zero for cards of a single deck.
+	Copy int `json:"copy,omitempty" bson:"copy,omitempty"`
+}
+
+// Canonical codes are one value rune followed by one suit rune, with T for
+// ten, e.g. AS, TH, 7C. Jokers are X1, X2 and so on.
+var valueCodes = map[string]string{
+	"ACE": "A", "2": "2", "3": "3", "4": "4", "5": "5", "6": "6", "7": "7",
+	"8": "8", "9": "9", "10": "T", "JACK": "J", "QUEEN": "Q", "KING": "K",
+}
+
+var suitCodes = map[string]string{
+	"SPADES": "S", "DIAMONDS": "D", "CLUBS": "C", "HEARTS": "H",
+}
+
+// valueAliases maps every accepted spelling of a value to its name.
+var valueAliases = map[string]string{
+	"A": "ACE", "2": "2", "3": "3", "4": "4", "5": "5", "6": "6", "7": "7",
+	"8": "8", "9": "9", "T": "10", "0": "10", "10": "10", "J": "JACK", "Q": "QUEEN", "K": "KING",
+}
+
+// suitAliases maps suit letters and both the filled and outlined Unicode
+// symbols to the suit name.
+var suitAliases = map[rune]string{
+	'S': "SPADES", '♠': "SPADES", '♤': "SPADES",
+	'H': "HEARTS", '♥': "HEARTS", '♡': "HEARTS",
+	'D': "DIAMONDS", '♦': "DIAMONDS", '♢': "DIAMONDS",
+	'C': "CLUBS", '♣': "CLUBS", '♧': "CLUBS",
+}
+
+// NewCard builds the card of the given value and suit names with its canonical code.
+func NewCard(value string, suit string) Card {
+	return Card{
+		Value: value,
+		Suit:  suit,
+		Code:  valueCodes[value] + suitCodes[suit],
+	}
+}
+
+// ParseCard reads a card code in any common notation, ignoring case and
+// surrounding whitespace. Tens may be written T, 0 or 10 and suits as letters
+// or the symbols ♠♥♦♣, so "TS", "0s", "10S" and "10♠" are all the ten of spades.
+func ParseCard(code string) (Card, error) {
+	runes := []rune(strings.ToUpper(strings.TrimSpace(code)))
+	if len(runes) < 2 {
+		return Card{}, fmt.Errorf("invalid card code %q", code)
+	}
+	if runes[0] == 'X' {
+		n, err := strconv.Atoi(string(runes[1:]))
+		if err != nil || n < 1 || n > MaxJokersPerDeck {
+			return Card{}, fmt.Errorf("invalid card code %q", code)
+		}
+		return jokerCard(n), nil
+	}
+	value, valueOk := valueAliases[string(runes[:len(runes)-1])]
+	suit, suitOk := suitAliases[runes[len(runes)-1]]
+	if !valueOk || !suitOk {
+		return Card{}, fmt.Errorf("invalid card code %q", code)
+	}
+	return NewCard(value, suit), nil
+}
+
+// Canonical returns the card with its code rebuilt from its value and suit,
+// which upgrades codes stored before tens were coded as T.
+func (c Card) Canonical() Card {
+	if c.Value == "JOKER" {
+		return c
+	}
+	canonical := NewCard(c.Value, c.Suit)
+	canonical.Copy = c.Copy
+	return canonical
+}
+
+// CardCodeError lists every code of a cards query that could not be used.
+type CardCodeError struct {
+	Invalid    []string `json:"invalid_codes,omitempty"`
+	Duplicates []string `json:"duplicate_codes,omitempty"`
+	// NotInDeck lists well formed codes of cards the deck never held.
+	NotInDeck []string `json:"not_in_deck_codes,omitempty"`
+	// NotDrawn lists cards that belong to the deck but are not out of it.
+	NotDrawn []string `json:"not_drawn_codes,omitempty"`
+	// Missing lists cards that are not in the pile they were asked from.
+	Missing []string `json:"missing_codes,omitempty"`
+}
+
+func (e *CardCodeError) Error() string {
+	var problems []string
+	if len(e.Invalid) > 0 {
+		problems = append(problems, fmt.Sprintf("invalid card codes: %s", strings.Join(e.Invalid, ",")))
+	}
+	if len(e.Duplicates) > 0 {
+		problems = append(problems, fmt.Sprintf("duplicate card codes: %s", strings.Join(e.Duplicates, ",")))
+	}
+	if len(e.NotInDeck) > 0 {
+		problems = append(problems, fmt.Sprintf("card codes not in the deck: %s", strings.Join(e.NotInDeck, ",")))
+	}
+	if len(e.NotDrawn) > 0 {
+		problems = append(problems, fmt.Sprintf("card codes not drawn from the deck: %s", strings.Join(e.NotDrawn, ",")))
+	}
+	if len(e.Missing) > 0 {
+		problems = append(problems, fmt.Sprintf("card codes not in the pile: %s", strings.Join(e.Missing, ",")))
+	}
+	return strings.Join(problems, "; ")
+}
+
+// ParseCards splits a comma separated list of card codes and reads each with
+// ParseCard, keeping the order and any repeats. A *CardCodeError lists every
+// code that could not be read.
+func ParseCards(input string) ([]Card, error) {
+	var cards []Card
+	codeErr := &CardCodeError{}
+	for _, raw := range strings.Split(input, ",") {
+		card, err := ParseCard(raw)
+		if err != nil {
+			codeErr.Invalid = append(codeErr.Invalid, strings.TrimSpace(raw))
+			continue
+		}
+		cards = append(cards, card)
+	}
+	if len(codeErr.Invalid) > 0 {
+		return nil, codeErr
+	}
+	return cards, nil
+}
+
+// ParseCardCodes splits a comma separated list of card codes, reads each with
+// ParseCard and returns their canonical codes in the order they were listed.
+// Every code must be in valid and listed only once, otherwise a
+// *CardCodeError reports all offending codes.
+func ParseCardCodes(input string, valid map[string]bool) ([]string, error) {
+	var codes []string
+	codeErr := &CardCodeError{}
+	seen := make(map[string]bool)
+	for _, raw := range strings.Split(input, ",") {
+		card, err := ParseCard(raw)
+		code := card.Code
+		if err != nil || !valid[code] {
+			codeErr.Invalid = append(codeErr.Invalid, strings.TrimSpace(raw))
+			continue
+		}
+		if seen[code] {
+			codeErr.Duplicates = append(codeErr.Duplicates, code)
+			continue
+		}
+		seen[code] = true
+		codes = append(codes, code)
+	}
+	if len(codeErr.Invalid) > 0 || len(codeErr.Duplicates) > 0 {
+		return nil, codeErr
+	}
+	return codes, nil
 }
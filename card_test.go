@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCard(t *testing.T) {
+	t.Run("Aliases map to one card", func(t *testing.T) {
+		tests := []struct {
+			codes    []string
+			expected Card
+		}{
+			{[]string{"TS", "ts", "0S", "10S", "10s", " T♠ ", "10♤"}, Card{Value: "10", Suit: "SPADES", Code: "TS"}},
+			{[]string{"AH", "a♥", "A♡"}, Card{Value: "ACE", Suit: "HEARTS", Code: "AH"}},
+			{[]string{"KD", "k♦", "K♢"}, Card{Value: "KING", Suit: "DIAMONDS", Code: "KD"}},
+			{[]string{"7C", "7♣", "7♧"}, Card{Value: "7", Suit: "CLUBS", Code: "7C"}},
+			{[]string{"X2", "x2"}, Card{Value: "JOKER", Suit: "RED", Code: "X2"}},
+		}
+		for _, tt := range tests {
+			for _, code := range tt.codes {
+				actual, err := ParseCard(code)
+				if err != nil {
+					t.Errorf("Failed to parse %q: %v", code, err)
+				}
+				if actual != tt.expected {
+					t.Errorf("Card parsed from %q is incorrect, expected: %v, actual: %v", code, tt.expected, actual)
+				}
+			}
+		}
+	})
+	t.Run("Invalid codes", func(t *testing.T) {
+		for _, code := range []string{"", "A", "1S", "11S", "ZZ", "AX", "X0", "X9", "10"} {
+			if _, err := ParseCard(code); err == nil {
+				t.Errorf("An error is expected to return for %q", code)
+			}
+		}
+	})
+	t.Run("Every generated card parses back to itself", func(t *testing.T) {
+		deck := Deck{Jokers: 2}
+		deck.GenerateCards(false)
+		for _, card := range deck.Cards {
+			actual, err := ParseCard(card.Code)
+			if err != nil || actual != card {
+				t.Errorf("Card %v does not round trip, actual: %v, error: %v", card, actual, err)
+			}
+		}
+	})
+	t.Run("Legacy ten codes are upgraded", func(t *testing.T) {
+		actual := Card{Value: "10", Suit: "HEARTS", Code: "1H", Copy: 3}.Canonical()
+		if actual.Code != "TH" || actual.Copy != 3 {
+			t.Errorf("Legacy card should get the canonical code, expected: %v, actual: %v", "TH", actual)
+		}
+	})
+}
+
+func TestParseCardCodes(t *testing.T) {
+	valid := DeckOptions{}.CardCodes()
+	actual, err := ParseCardCodes("10♠, 0h,TD ,as", valid)
+	if err != nil {
+		t.Errorf("Failed to parse card codes: %v", err)
+	}
+	if strings.Join(actual, ",") != "TS,TH,TD,AS" {
+		t.Errorf("Card codes should be canonical and in order, expected: %v, actual: %v", "TS,TH,TD,AS", actual)
+	}
+
+	_, err = ParseCardCodes("TS,10S", valid)
+	codeErr, ok := err.(*CardCodeError)
+	if !ok || strings.Join(codeErr.Duplicates, ",") != "TS" {
+		t.Errorf("Aliases of the same card should be reported as duplicates, actual: %v", err)
+	}
+}
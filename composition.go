@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+const StandardComposition = "standard"
+
+// MaxJokersPerDeck is the largest number of jokers added to each deck of a shoe.
+const MaxJokersPerDeck = 4
+
+// Composition is a named selection of card values making up one deck.
+// Every value comes once per suit, or Copies times for doubled decks.
+type Composition struct {
+	Name   string
+	Values []string
+	Copies int
+}
+
+var compositions = map[string]Composition{
+	StandardComposition: {
+		Name:   StandardComposition,
+		Values: []string{"ACE", "2", "3", "4", "5", "6", "7", "8", "9", "10", "JACK", "QUEEN", "KING"},
+		Copies: 1,
+	},
+	"piquet": {
+		Name:   "piquet",
+		Values: []string{"ACE", "7", "8", "9", "10", "JACK", "QUEEN", "KING"},
+		Copies: 1,
+	},
+	"euchre": {
+		Name:   "euchre",
+		Values: []string{"ACE", "9", "10", "JACK", "QUEEN", "KING"},
+		Copies: 1,
+	},
+	"spanish": {
+		Name:   "spanish",
+		Values: []string{"ACE", "2", "3", "4", "5", "6", "7", "JACK", "QUEEN", "KING"},
+		Copies: 1,
+	},
+	"pinochle": {
+		Name:   "pinochle",
+		Values: []string{"ACE", "9", "10", "JACK", "QUEEN", "KING"},
+		Copies: 2,
+	},
+}
+
+// LookupComposition returns the composition registered under name, an empty
+// name meaning the standard 52-card deck.
+func LookupComposition(name string) (Composition, error) {
+	if name == "" {
+		name = StandardComposition
+	}
+	composition, exists := compositions[name]
+	if !exists {
+		return Composition{}, fmt.Errorf("unknown composition %q, expected one of %v", name, CompositionNames())
+	}
+	return composition, nil
+}
+
+func CompositionNames() []string {
+	var names []string
+	for name := range compositions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func jokerCard(n int) Card {
+	suit := "BLACK"
+	if n%2 == 0 {
+		suit = "RED"
+	}
+	return Card{
+		Value: "JOKER",
+		Suit:  suit,
+		Code:  fmt.Sprintf("X%d", n),
+	}
+}
+
+func jokers(count int) []Card {
+	var cards []Card
+	for i := 1; i <= count; i++ {
+		cards = append(cards, jokerCard(i))
+	}
+	return cards
+}
@@ -3,93 +3,284 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"os"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+	"time"
 )
 
-var DbClient *mongo.Client
+// MongoStore keeps one document per deck in a MongoDB collection.
+type MongoStore struct {
+	client *mongo.Client
+	coll   *mongo.Collection
+	// responses holds the responses kept for idempotency keys, next to coll.
+	responses *mongo.Collection
+	// tables holds the Hold'em tables, next to coll.
+	tables *mongo.Collection
+}
 
-func SetupDb() {
-	connectionString := os.Getenv("MONGO_CONNECTION_STRING")
+func NewMongoStore(connectionString string, dbName string, collectionName string) (*MongoStore, error) {
 	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(connectionString))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	DbClient = client
+	db := client.Database(dbName)
+	return &MongoStore{
+		client:    client,
+		coll:      db.Collection(collectionName),
+		responses: db.Collection(collectionName + "_responses"),
+		tables:    db.Collection(collectionName + "_tables"),
+	}, nil
 }
 
-func InsertDeck(deck Deck) (interface{}, error) {
-	dbName := os.Getenv("DB_NAME")
-	collectionName := os.Getenv("POKER_COLLECTION_NAME")
-	coll := DbClient.Database(dbName).Collection(collectionName)
-	result, err := coll.InsertOne(context.TODO(), deck)
+// EnsureIndexes creates the indexes the store relies on. Creating an index that
+// already exists is a no-op, so it is safe to call on every start.
+func (s *MongoStore) EnsureIndexes() error {
+	// ListDecks sorts by created_at and _id, and its most selective filters
+	// are owner and tag
+	_, err := s.coll.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "owner", Value: 1}, {Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}, {Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		// decks created with a ttl are removed once expires_at passes
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
 	if err != nil {
-		return result, err
+		return storeError(err)
+	}
+	_, err = s.responses.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return storeError(err)
+}
+
+func (s *MongoStore) InsertDeck(deck Deck) (interface{}, error) {
+	result, err := s.coll.InsertOne(context.TODO(), deck)
+	if err != nil {
+		return result, storeError(err)
 	}
 	return result.InsertedID, err
 }
 
-func GetDeck(deckId string) (Deck, error) {
-	dbName := os.Getenv("DB_NAME")
-	collectionName := os.Getenv("POKER_COLLECTION_NAME")
-	coll := DbClient.Database(dbName).Collection(collectionName)
+func (s *MongoStore) GetDeck(deckId string) (Deck, error) {
 	var result Deck
-	err := coll.FindOne(context.TODO(), bson.D{{"_id", deckId}}).Decode(&result)
-	return result, err
+	err := s.coll.FindOne(context.TODO(), bson.D{{Key: "_id", Value: deckId}}).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) || err == nil && result.expired(time.Now()) {
+		return Deck{}, ErrDeckNotFound
+	}
+	return result, storeError(err)
 }
 
-func DrawCardsFromDeck(deckId string, count int) ([]Card, error) {
-	var cards []Card
+// UpdateDeck applies update to the latest copy of the deck and writes it back
+// only if nobody else changed the deck in between, retrying otherwise.
+func (s *MongoStore) UpdateDeck(deckId string, update func(deck *Deck) error) (Deck, error) {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		deck, err := s.GetDeck(deckId)
+		if err != nil {
+			return deck, err
+		}
+		if err = applyUpdate(&deck, update); err != nil {
+			return Deck{}, err
+		}
+
+		filter := bson.D{{Key: "_id", Value: deckId}, {Key: "version", Value: deck.Version}}
+		if deck.Version == 0 {
+			// decks written before versioning have no version field at all
+			filter = bson.D{{Key: "_id", Value: deckId}, {Key: "version", Value: bson.D{{Key: "$in", Value: bson.A{0, nil}}}}}
+		}
+		deck.Version++
+		result, err := s.coll.ReplaceOne(context.TODO(), filter, deck)
+		if err != nil {
+			return Deck{}, storeError(err)
+		}
+		if result.MatchedCount == 1 {
+			return deck, nil
+		}
+	}
+	return Deck{}, ErrConcurrentUpdate
+}
 
-	deck, err := GetDeck(deckId)
+func (s *MongoStore) DrawCardsFromDeck(deckId string, count int) ([]Card, error) {
+	var cards []Card
+	_, err := s.UpdateDeck(deckId, func(deck *Deck) error {
+		var err error
+		cards, err = deck.PopCards(count)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	return cards, nil
+}
 
-	if len(deck.Cards) < count {
-		return nil, errors.New("deck has less cards than count intended to draw")
+func (s *MongoStore) ListDecks(filter DeckFilter, after *DeckCursor, limit int) ([]Deck, error) {
+	// the TTL monitor removes expired decks only about once a minute
+	query := bson.D{{Key: "$and", Value: bson.A{
+		bson.D{{Key: "archived_at", Value: bson.D{{Key: "$exists", Value: filter.Archived}}}},
+		bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "expires_at", Value: nil}},
+			bson.D{{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}}},
+		}}},
+	}}}
+	if filter.Shuffled != nil {
+		query = append(query, bson.E{Key: "shuffled", Value: *filter.Shuffled})
 	}
-
-	for i := 0; i < count; i++ {
-		var removedCard Card
-		removedCard, deck.Cards = deck.Cards[len(deck.Cards)-1], deck.Cards[:len(deck.Cards)-1]
-		cards = append(cards, removedCard)
+	remaining := bson.D{}
+	if filter.MinRemaining != nil {
+		remaining = append(remaining, bson.E{Key: "$gte", Value: *filter.MinRemaining})
+	}
+	if filter.MaxRemaining != nil {
+		remaining = append(remaining, bson.E{Key: "$lte", Value: *filter.MaxRemaining})
+	}
+	if len(remaining) > 0 {
+		query = append(query, bson.E{Key: "remaining", Value: remaining})
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query = append(query, bson.E{Key: "created_at", Value: bson.D{{Key: "$gt", Value: filter.CreatedAfter}}})
+	}
+	if filter.Owner != "" {
+		query = append(query, bson.E{Key: "owner", Value: filter.Owner})
+	}
+	if filter.Tag != "" {
+		query = append(query, bson.E{Key: "tags", Value: filter.Tag})
+	}
+	if after != nil {
+		// decks created before timestamps existed have no created_at, which
+		// sorts before every date
+		var sameTime interface{} = after.CreatedAt
+		laterTime := bson.D{{Key: "$gt", Value: after.CreatedAt}}
+		if after.CreatedAt.IsZero() {
+			sameTime = nil
+			laterTime = bson.D{{Key: "$ne", Value: nil}}
+		}
+		query = append(query, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "created_at", Value: laterTime}},
+			bson.D{{Key: "created_at", Value: sameTime}, {Key: "_id", Value: bson.D{{Key: "$gt", Value: after.DeckId}}}},
+		}})
 	}
-	dbName := os.Getenv("DB_NAME")
-	collectionName := os.Getenv("POKER_COLLECTION_NAME")
-	coll := DbClient.Database(dbName).Collection(collectionName)
 
-	var cardCodes []string
-	for _, card := range cards {
-		cardCodes = append(cardCodes, card.Code)
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.D{{Key: "cards", Value: 0}, {Key: "drawn", Value: 0}, {Key: "piles", Value: 0}})
+	cursor, err := s.coll.Find(context.TODO(), query, opts)
+	if err != nil {
+		return nil, storeError(err)
 	}
+	var decks []Deck
+	if err = cursor.All(context.TODO(), &decks); err != nil {
+		return nil, storeError(err)
+	}
+	return decks, nil
+}
 
-	update := bson.D{{
-		"$pull",
-		bson.D{{
-			"cards",
-			bson.D{{
-				"code",
-				bson.D{{
-					"$in",
-					cardCodes,
-				}},
-			}},
-		}},
-	}, {
-		"$set",
-		bson.D{{
-			"remaining",
-			len(deck.Cards),
+func (s *MongoStore) DeleteDeck(deckId string) error {
+	filter := bson.D{
+		{Key: "_id", Value: deckId},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "expires_at", Value: nil}},
+			bson.D{{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}}},
 		}},
-	}}
+	}
+	result, err := s.coll.DeleteOne(context.TODO(), filter)
+	if err != nil {
+		return storeError(err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrDeckNotFound
+	}
+	return nil
+}
 
-	_, err = coll.UpdateOne(context.TODO(), bson.D{{"_id", deckId}}, update)
+func (s *MongoStore) ReserveResponse(response StoredResponse) (StoredResponse, bool, error) {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		// the TTL monitor removes expired responses only about once a minute, so
+		// an expired one may still be there and is replaced. An unexpired one
+		// fails the filter, and the upsert then fails on the duplicate _id.
+		filter := bson.D{
+			{Key: "_id", Value: response.Id},
+			{Key: "expires_at", Value: bson.D{{Key: "$lte", Value: time.Now()}}},
+		}
+		_, err := s.responses.ReplaceOne(context.TODO(), filter, response, options.Replace().SetUpsert(true))
+		if err == nil {
+			return response, true, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return StoredResponse{}, false, storeError(err)
+		}
+		var stored StoredResponse
+		err = s.responses.FindOne(context.TODO(), bson.D{{Key: "_id", Value: response.Id}}).Decode(&stored)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// deleted in between, try to reserve it again
+			continue
+		}
+		return stored, false, storeError(err)
+	}
+	return StoredResponse{}, false, ErrConcurrentUpdate
+}
 
-	if err != nil {
-		return nil, err
+func (s *MongoStore) SaveResponse(response StoredResponse) error {
+	_, err := s.responses.ReplaceOne(context.TODO(), bson.D{{Key: "_id", Value: response.Id}}, response,
+		options.Replace().SetUpsert(true))
+	return storeError(err)
+}
+
+func (s *MongoStore) DeleteResponse(id string) error {
+	_, err := s.responses.DeleteOne(context.TODO(), bson.D{{Key: "_id", Value: id}})
+	return storeError(err)
+}
+
+func (s *MongoStore) InsertTable(table Table) error {
+	_, err := s.tables.InsertOne(context.TODO(), table)
+	return storeError(err)
+}
+
+func (s *MongoStore) GetTable(tableId string) (Table, error) {
+	var result Table
+	err := s.tables.FindOne(context.TODO(), bson.D{{Key: "_id", Value: tableId}}).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Table{}, ErrTableNotFound
 	}
-	return cards, nil
+	return result, storeError(err)
+}
+
+// UpdateTable writes the table back only if nobody else changed it in
+// between, retrying otherwise, the same way UpdateDeck does.
+func (s *MongoStore) UpdateTable(tableId string, update func(table *Table) error) (Table, error) {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		table, err := s.GetTable(tableId)
+		if err != nil {
+			return table, err
+		}
+		if err = applyTableUpdate(&table, update); err != nil {
+			return Table{}, err
+		}
+
+		filter := bson.D{{Key: "_id", Value: tableId}, {Key: "version", Value: table.Version}}
+		table.Version++
+		result, err := s.tables.ReplaceOne(context.TODO(), filter, table)
+		if err != nil {
+			return Table{}, storeError(err)
+		}
+		if result.MatchedCount == 1 {
+			return table, nil
+		}
+	}
+	return Table{}, ErrConcurrentUpdate
+}
+
+// storeError reports failures to reach MongoDB as ErrStoreUnavailable.
+func storeError(err error) error {
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) ||
+		errors.Is(err, topology.ErrServerSelectionTimeout) || errors.Is(err, mongo.ErrClientDisconnected) {
+		return fmt.Errorf("%w: %v", ErrStoreUnavailable, err)
+	}
+	return err
+}
+
+func (s *MongoStore) Close() error {
+	return s.client.Disconnect(context.TODO())
 }
@@ -7,12 +7,23 @@ import (
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/bson"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestDatabaseOperations(t *testing.T) {
 	_ = godotenv.Load("test.env")
-	SetupDb()
+	for _, ts := range testStores(t) {
+		t.Run(ts.name, func(t *testing.T) {
+			testDeckStore(t, ts.store)
+			TeardownDb(ts.store)
+		})
+	}
+}
+
+func testDeckStore(t *testing.T, store DeckStore) {
 	t.Run("Create deck", func(t *testing.T) {
 		expected := uuid.NewString()
 		deck := Deck{
@@ -21,7 +32,7 @@ func TestDatabaseOperations(t *testing.T) {
 			Remaining: 0,
 			Cards:     nil,
 		}
-		actual, err := InsertDeck(deck)
+		actual, err := store.InsertDeck(deck)
 		if err != nil {
 			t.Errorf("Failed to insert to MongoDB: %v", err)
 		}
@@ -29,10 +40,24 @@ func TestDatabaseOperations(t *testing.T) {
 			t.Errorf("DeckID is different from _id inserted in mongodb, expected: %v, actual: %v", expected, actual)
 		}
 	})
+	t.Run("Create partial deck from card code aliases", func(t *testing.T) {
+		deck, err := CreateDeck(store, DeckOptions{Shuffled: true, Cards: []string{"ts", "10h", "AS"}})
+		if err != nil {
+			t.Fatalf("Failed to create deck: %v", err)
+		}
+		opened, err := OpenDeck(store, deck.DeckId)
+		if err != nil || opened.Remaining != 3 || strings.Join(opened.RequestedCards, ",") != "TS,TH,AS" {
+			t.Fatalf("Aliases should be stored as canonical codes, deck: %+v, error: %v", opened, err)
+		}
+		original, err := opened.OriginalCards()
+		if err != nil || !sameOrder(original, opened.Cards) {
+			t.Errorf("Partial deck should replay its order, original: %v, cards: %v, error: %v", original, opened.Cards, err)
+		}
+	})
 	t.Run("Open deck", func(t *testing.T) {
 		expected := uuid.NewString()
-		SeedDb(expected)
-		actual, err := OpenDeck(expected)
+		SeedDb(store, expected)
+		actual, err := OpenDeck(store, expected)
 		if err != nil {
 			t.Errorf("Failed to get data from mongodb: %v", err)
 		}
@@ -45,8 +70,8 @@ func TestDatabaseOperations(t *testing.T) {
 	})
 	t.Run("Open invalid deck", func(t *testing.T) {
 		expected := uuid.NewString()
-		SeedDb(uuid.NewString())
-		actual, err := OpenDeck(expected)
+		SeedDb(store, uuid.NewString())
+		actual, err := OpenDeck(store, expected)
 		if err == nil {
 			t.Errorf("An error is expected to return")
 		}
@@ -56,8 +81,8 @@ func TestDatabaseOperations(t *testing.T) {
 	})
 	t.Run("Open deck with empty DeckID", func(t *testing.T) {
 		expected := ""
-		SeedDb(uuid.NewString())
-		actual, err := OpenDeck(expected)
+		SeedDb(store, uuid.NewString())
+		actual, err := OpenDeck(store, expected)
 		if err == nil {
 			t.Errorf("An error is expected to return")
 		}
@@ -68,9 +93,9 @@ func TestDatabaseOperations(t *testing.T) {
 	t.Run("Draw 0 cards", func(t *testing.T) {
 		expected := 0
 		deckId := uuid.NewString()
-		SeedDb(deckId)
-		actual, err := DrawCardsFromDeck(deckId, expected)
-		deck, err := OpenDeck(deckId)
+		SeedDb(store, deckId)
+		actual, err := store.DrawCardsFromDeck(deckId, expected)
+		deck, err := OpenDeck(store, deckId)
 		if err != nil {
 			t.Errorf("Failed to get data from mongodb: %v", err)
 		}
@@ -84,9 +109,9 @@ func TestDatabaseOperations(t *testing.T) {
 	t.Run("Draw 1 card", func(t *testing.T) {
 		expected := 1
 		deckId := uuid.NewString()
-		SeedDb(deckId)
-		actual, err := DrawCardsFromDeck(deckId, expected)
-		deck, err := OpenDeck(deckId)
+		SeedDb(store, deckId)
+		actual, err := store.DrawCardsFromDeck(deckId, expected)
+		deck, err := OpenDeck(store, deckId)
 		if err != nil {
 			t.Errorf("Failed to get data from mongodb: %v", err)
 		}
@@ -100,9 +125,9 @@ func TestDatabaseOperations(t *testing.T) {
 	t.Run("Draw multiple cards", func(t *testing.T) {
 		expected := 2
 		deckId := uuid.NewString()
-		SeedDb(deckId)
-		actual, err := DrawCardsFromDeck(deckId, expected)
-		deck, err := OpenDeck(deckId)
+		SeedDb(store, deckId)
+		actual, err := store.DrawCardsFromDeck(deckId, expected)
+		deck, err := OpenDeck(store, deckId)
 		if err != nil {
 			t.Errorf("Failed to get data from mongodb: %v", err)
 		}
@@ -116,8 +141,8 @@ func TestDatabaseOperations(t *testing.T) {
 	t.Run("Draw -1 card", func(t *testing.T) {
 		expected := 0
 		deckId := uuid.NewString()
-		SeedDb(deckId)
-		actual, err := DrawCardsFromDeck(deckId, -1)
+		SeedDb(store, deckId)
+		actual, err := store.DrawCardsFromDeck(deckId, -1)
 		if err == nil {
 			t.Errorf("An error is expected to return")
 		}
@@ -128,8 +153,8 @@ func TestDatabaseOperations(t *testing.T) {
 	t.Run("Draw more cards than a deck has", func(t *testing.T) {
 		expected := 0
 		deckId := uuid.NewString()
-		SeedDb(deckId)
-		actual, err := DrawCardsFromDeck(deckId, 3)
+		SeedDb(store, deckId)
+		actual, err := store.DrawCardsFromDeck(deckId, 3)
 		if err == nil {
 			t.Errorf("An error is expected to return")
 		}
@@ -140,8 +165,8 @@ func TestDatabaseOperations(t *testing.T) {
 	t.Run("Draw card from invalid deck", func(t *testing.T) {
 		expected := 0
 		deckId := uuid.NewString()
-		SeedDb(uuid.NewString())
-		actual, err := DrawCardsFromDeck(deckId, 3)
+		SeedDb(store, uuid.NewString())
+		actual, err := store.DrawCardsFromDeck(deckId, 3)
 		if err == nil {
 			t.Errorf("An error is expected to return")
 		}
@@ -149,11 +174,629 @@ func TestDatabaseOperations(t *testing.T) {
 			t.Errorf("Cards drew should be empty, expected: %v, actual %v", expected, len(actual))
 		}
 	})
+	t.Run("Draw from shoe removes only the drawn copy", func(t *testing.T) {
+		deckId := uuid.NewString()
+		deck := Deck{
+			DeckId:    deckId,
+			Remaining: 2,
+			Decks:     2,
+			Cards: []Card{
+				{Value: "ACE", Suit: "HEARTS", Code: "AH", Copy: 1},
+				{Value: "ACE", Suit: "HEARTS", Code: "AH", Copy: 2},
+			},
+		}
+		if _, err := store.InsertDeck(deck); err != nil {
+			t.Fatalf("Failed to insert deck: %v", err)
+		}
+		actual, err := store.DrawCardsFromDeck(deckId, 1)
+		if err != nil {
+			t.Errorf("Failed to draw from shoe: %v", err)
+		}
+		if len(actual) != 1 || actual[0].Copy != 2 {
+			t.Errorf("The top copy should be drawn, expected: %v, actual: %v", 2, actual)
+		}
+		opened, err := OpenDeck(store, deckId)
+		if err != nil {
+			t.Errorf("Failed to get data from store: %v", err)
+		}
+		if opened.Remaining != 1 || len(opened.Cards) != 1 || opened.Cards[0].Copy != 1 {
+			t.Errorf("Only the drawn copy should be removed, remaining: %v, cards: %v", opened.Remaining, opened.Cards)
+		}
+	})
+	t.Run("Return drawn cards", func(t *testing.T) {
+		deckId := uuid.NewString()
+		SeedDb(store, deckId)
+		drawn, err := store.DrawCardsFromDeck(deckId, 2)
+		if err != nil {
+			t.Fatalf("Failed to draw from store: %v", err)
+		}
+		if _, err = ReturnCards(store, deckId, drawn[:1], ReturnBottom); err != nil {
+			t.Errorf("Failed to return card: %v", err)
+		}
+		if _, err = ReturnCards(store, deckId, drawn[1:], ReturnTop); err != nil {
+			t.Errorf("Failed to return card: %v", err)
+		}
+		deck, err := OpenDeck(store, deckId)
+		if err != nil {
+			t.Errorf("Failed to get data from store: %v", err)
+		}
+		if deck.Remaining != 2 || len(deck.Cards) != 2 {
+			t.Errorf("Remaining cards in deck doesn't match expected count, expected: %v, actual: %v", 2, deck.Remaining)
+		}
+		if len(deck.Cards) == 2 && (deck.Cards[0].Code != drawn[0].Code || deck.Cards[1].Code != drawn[1].Code) {
+			t.Errorf("Cards should be returned to the bottom and top, expected: %v, actual: %v", drawn, deck.Cards)
+		}
+	})
+	t.Run("Return cards that were not drawn", func(t *testing.T) {
+		deckId := uuid.NewString()
+		SeedDb(store, deckId)
+		_, err := store.DrawCardsFromDeck(deckId, 1)
+		if err != nil {
+			t.Fatalf("Failed to draw from store: %v", err)
+		}
+		kingOfSpades, _ := ParseCard("KS")
+		aceOfHearts, _ := ParseCard("AH")
+		_, err = ReturnCards(store, deckId, []Card{kingOfSpades, aceOfHearts}, ReturnTop)
+		codeErr, ok := err.(*CardCodeError)
+		if !ok {
+			t.Fatalf("A card code error is expected to return, actual: %v", err)
+		}
+		if len(codeErr.NotInDeck) != 1 || codeErr.NotInDeck[0] != "KS" {
+			t.Errorf("Cards that never belonged to the deck should be reported as not in the deck, actual: %v", codeErr.NotInDeck)
+		}
+		if len(codeErr.NotDrawn) != 1 || codeErr.NotDrawn[0] != "AH" {
+			t.Errorf("Cards still in the deck should be reported, actual: %v", codeErr.NotDrawn)
+		}
+		deck, _ := OpenDeck(store, deckId)
+		if deck.Remaining != 1 {
+			t.Errorf("A rejected return should leave the deck untouched, expected: %v, actual: %v", 1, deck.Remaining)
+		}
+	})
+	t.Run("Move cards between piles", func(t *testing.T) {
+		deckId := uuid.NewString()
+		SeedDb(store, deckId)
+		moved, err := MoveCards(store, deckId, "discard", "", 2, nil)
+		if err != nil || len(moved) != 2 {
+			t.Fatalf("Failed to move cards to the discard pile: %v", err)
+		}
+		twoOfHearts, _ := ParseCard("2H")
+		if _, err = MoveCards(store, deckId, "burn", "discard", 0, []Card{twoOfHearts}); err != nil {
+			t.Errorf("Failed to move a card between piles: %v", err)
+		}
+		deck, err := OpenDeck(store, deckId)
+		if err != nil {
+			t.Errorf("Failed to get data from store: %v", err)
+		}
+		if deck.Remaining != 0 || len(deck.Piles["discard"]) != 1 || len(deck.Piles["burn"]) != 1 {
+			t.Errorf("Piles don't match expected counts, remaining: %v, piles: %v", deck.Remaining, deck.Piles)
+		}
+		if len(deck.Piles["burn"]) == 1 && deck.Piles["burn"][0].Code != "2H" {
+			t.Errorf("The requested card should be moved, expected: %v, actual: %v", "2H", deck.Piles["burn"][0].Code)
+		}
+
+		if _, err = ShufflePileIntoDeck(store, deckId, "discard"); err != nil {
+			t.Errorf("Failed to shuffle the pile into the deck: %v", err)
+		}
+		if _, err = ListPile(store, deckId, "discard"); err != ErrPileNotFound {
+			t.Errorf("A shuffled in pile should be gone, actual: %v", err)
+		}
+		deck, _ = OpenDeck(store, deckId)
+		if deck.Remaining != 1 || !deck.Shuffled {
+			t.Errorf("Pile should be back in the shuffled deck, remaining: %v, shuffled: %v", deck.Remaining, deck.Shuffled)
+		}
+	})
+	t.Run("Concurrent moves never lose a card", func(t *testing.T) {
+		deck := Deck{DeckId: uuid.NewString()}
+		deck.GenerateCards(false)
+		deck.Remaining = len(deck.Cards)
+		if _, err := store.InsertDeck(deck); err != nil {
+			t.Fatalf("Failed to insert deck: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 26; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, _ = MoveCards(store, deck.DeckId, fmt.Sprintf("player%d", i%4), "", 2, nil)
+			}(i)
+		}
+		wg.Wait()
+
+		actual, err := OpenDeck(store, deck.DeckId)
+		if err != nil {
+			t.Errorf("Failed to get data from store: %v", err)
+		}
+		seen := make(map[string]bool)
+		for _, pile := range actual.Piles {
+			for _, c := range pile {
+				if seen[c.Code] {
+					t.Errorf("Card %v is in more than one pile", c.Code)
+				}
+				seen[c.Code] = true
+			}
+		}
+		if len(seen) != 52 || actual.Remaining != 0 {
+			t.Errorf("Every card should be in exactly one pile, expected: %v, actual: %v", 52, len(seen))
+		}
+	})
+	t.Run("Draw from positions", func(t *testing.T) {
+		deck := Deck{DeckId: uuid.NewString()}
+		deck.GenerateCards(false)
+		deck.Remaining = len(deck.Cards)
+		if _, err := store.InsertDeck(deck); err != nil {
+			t.Fatalf("Failed to insert deck: %v", err)
+		}
+		aceOfHearts, _ := ParseCard("AH")
+		tenOfClubs, _ := ParseCard("TC")
+		tests := []struct {
+			opts     DrawOptions
+			expected string
+		}{
+			{DrawOptions{Count: 1}, "KH"},
+			{DrawOptions{Count: 2, Position: DrawBottom}, "AS,2S"},
+			{DrawOptions{Count: 2, Position: DrawAt, Index: 1}, "JH,TH"},
+			{DrawOptions{Count: 2, Codes: []Card{tenOfClubs, aceOfHearts}}, "TC,AH"},
+		}
+		for _, tt := range tests {
+			actual, err := DrawCards(store, deck.DeckId, tt.opts)
+			if err != nil {
+				t.Errorf("Failed to draw %v: %v", tt.opts, err)
+			}
+			var codes []string
+			for _, c := range actual {
+				codes = append(codes, c.Code)
+			}
+			if strings.Join(codes, ",") != tt.expected {
+				t.Errorf("Cards drew is not matching for %v, expected: %v, actual: %v", tt.opts, tt.expected, codes)
+			}
+		}
+		random, err := DrawCards(store, deck.DeckId, DrawOptions{Count: 3, Position: DrawRandom})
+		if err != nil || len(random) != 3 {
+			t.Errorf("Failed to draw at random: %v", err)
+		}
+		opened, _ := OpenDeck(store, deck.DeckId)
+		if opened.Remaining != 52-10 {
+			t.Errorf("Remaining cards in deck doesn't match expected count, expected: %v, actual: %v", 52-10, opened.Remaining)
+		}
+	})
+	t.Run("Draw specific cards that are not in the deck", func(t *testing.T) {
+		deckId := uuid.NewString()
+		SeedDb(store, deckId)
+		aceOfHearts, _ := ParseCard("AH")
+		kingOfSpades, _ := ParseCard("KS")
+		if _, err := DrawCards(store, deckId, DrawOptions{Count: 1, Codes: []Card{aceOfHearts}}); err != nil {
+			t.Fatalf("Failed to draw a specific card: %v", err)
+		}
+		_, err := DrawCards(store, deckId, DrawOptions{Count: 2, Codes: []Card{aceOfHearts, kingOfSpades}})
+		codeErr, ok := err.(*CardCodeError)
+		if !ok {
+			t.Fatalf("A card code error is expected to return, actual: %v", err)
+		}
+		if strings.Join(codeErr.Missing, ",") != "AH" || strings.Join(codeErr.NotInDeck, ",") != "KS" {
+			t.Errorf("Drawn and foreign cards should be told apart, missing: %v, not in deck: %v", codeErr.Missing, codeErr.NotInDeck)
+		}
+	})
+	t.Run("Concurrent draws never deal a card twice", func(t *testing.T) {
+		deck := Deck{DeckId: uuid.NewString()}
+		deck.GenerateCards(true)
+		deck.Remaining = len(deck.Cards)
+		if _, err := store.InsertDeck(deck); err != nil {
+			t.Fatalf("Failed to insert deck: %v", err)
+		}
+
+		// more draws are attempted than the deck can satisfy on purpose
+		workers := 40
+		var wg sync.WaitGroup
+		results := make(chan []Card, workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cards, err := store.DrawCardsFromDeck(deck.DeckId, 2)
+				if err == nil {
+					results <- cards
+				}
+			}()
+		}
+		wg.Wait()
+		close(results)
+
+		seen := make(map[string]bool)
+		for cards := range results {
+			for _, c := range cards {
+				if seen[c.Code] {
+					t.Errorf("Card %v was dealt more than once", c.Code)
+				}
+				seen[c.Code] = true
+			}
+		}
+		if len(seen) != 52 {
+			t.Errorf("Every card should be dealt exactly once, expected: %v, actual: %v", 52, len(seen))
+		}
+		actual, err := OpenDeck(store, deck.DeckId)
+		if err != nil {
+			t.Errorf("Failed to get data from store: %v", err)
+		}
+		if actual.Remaining != 0 || len(actual.Cards) != 0 {
+			t.Errorf("Deck should be empty, remaining: %v, cards: %v", actual.Remaining, len(actual.Cards))
+		}
+	})
+	t.Run("List decks", func(t *testing.T) {
+		owner := uuid.NewString()
+		created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		var ids []string
+		for i := 0; i < 5; i++ {
+			deck := Deck{
+				DeckId:    fmt.Sprintf("%d-%s", i, uuid.NewString()),
+				Remaining: i * 10,
+				Shuffled:  i%2 == 0,
+				Owner:     owner,
+				// two decks share a creation time so the id breaks the tie
+				CreatedAt: created.Add(time.Duration(i/2*2) * time.Minute),
+			}
+			if i == 3 {
+				deck.Tags = []string{"table-7"}
+			}
+			if _, err := store.InsertDeck(deck); err != nil {
+				t.Fatalf("Failed to insert deck: %v", err)
+			}
+			ids = append(ids, deck.DeckId)
+		}
+
+		var listed []string
+		cursor := ""
+		for page := 0; page < 5; page++ {
+			result, err := ListDecks(store, DeckFilter{Owner: owner}, cursor, 2)
+			if err != nil {
+				t.Fatalf("Failed to list decks: %v", err)
+			}
+			for _, deck := range result.Decks {
+				listed = append(listed, deck.DeckId)
+			}
+			if cursor = result.NextCursor; cursor == "" {
+				break
+			}
+		}
+		if strings.Join(listed, ",") != strings.Join(ids, ",") {
+			t.Errorf("Every deck should be listed once in order, expected: %v, actual: %v", ids, listed)
+		}
+
+		shuffled := true
+		min, max := 10, 30
+		tests := []struct {
+			filter   DeckFilter
+			expected []string
+		}{
+			{DeckFilter{Owner: owner, Shuffled: &shuffled}, []string{ids[0], ids[2], ids[4]}},
+			{DeckFilter{Owner: owner, MinRemaining: &min, MaxRemaining: &max}, ids[1:4]},
+			{DeckFilter{Owner: owner, CreatedAfter: created.Add(time.Minute)}, ids[2:]},
+			{DeckFilter{Owner: owner, Tag: "table-7"}, ids[3:4]},
+			{DeckFilter{Owner: uuid.NewString()}, nil},
+		}
+		for _, tt := range tests {
+			result, err := ListDecks(store, tt.filter, "", MaxListLimit)
+			if err != nil {
+				t.Fatalf("Failed to list decks: %v", err)
+			}
+			var actual []string
+			for _, deck := range result.Decks {
+				actual = append(actual, deck.DeckId)
+			}
+			if strings.Join(actual, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Filtered decks are incorrect for %+v, expected: %v, actual: %v", tt.filter, tt.expected, actual)
+			}
+		}
+	})
+	t.Run("Timestamps follow changes", func(t *testing.T) {
+		created, err := CreateDeck(store, DeckOptions{Labels: map[string]string{"game_id": "g-1"}})
+		if err != nil {
+			t.Fatalf("Failed to create deck: %v", err)
+		}
+		if _, err = MoveCards(store, created.DeckId, "burn", "", 1, nil); err != nil {
+			t.Fatalf("Failed to move cards: %v", err)
+		}
+		moved, _ := OpenDeck(store, created.DeckId)
+		if moved.LastDrawnAt != nil || moved.UpdatedAt.Before(created.CreatedAt) {
+			t.Errorf("Only updated_at should change, updated: %v, last drawn: %v", moved.UpdatedAt, moved.LastDrawnAt)
+		}
+		if _, err = DrawCards(store, created.DeckId, DrawOptions{Count: 1}); err != nil {
+			t.Fatalf("Failed to draw: %v", err)
+		}
+		drawn, _ := OpenDeck(store, created.DeckId)
+		if drawn.LastDrawnAt == nil || drawn.LastDrawnAt.Before(created.CreatedAt) || drawn.Labels["game_id"] != "g-1" {
+			t.Errorf("Drawing should be stamped, last drawn: %v, labels: %v", drawn.LastDrawnAt, drawn.Labels)
+		}
+	})
+	t.Run("Archive and delete decks", func(t *testing.T) {
+		deckId := uuid.NewString()
+		owner := uuid.NewString()
+		if _, err := store.InsertDeck(Deck{DeckId: deckId, Owner: owner, Remaining: 2, Cards: []Card{NewCard("ACE", "HEARTS"), NewCard("2", "HEARTS")}}); err != nil {
+			t.Fatalf("Failed to insert deck: %v", err)
+		}
+		if _, err := ArchiveDeck(store, deckId); err != nil {
+			t.Fatalf("Failed to archive deck: %v", err)
+		}
+		if _, err := store.DrawCardsFromDeck(deckId, 1); err != ErrDeckArchived {
+			t.Errorf("An archived deck should not change, expected: %v, actual: %v", ErrDeckArchived, err)
+		}
+		active, _ := ListDecks(store, DeckFilter{Owner: owner}, "", MaxListLimit)
+		archived, _ := ListDecks(store, DeckFilter{Owner: owner, Archived: true}, "", MaxListLimit)
+		if len(active.Decks) != 0 || len(archived.Decks) != 1 {
+			t.Errorf("An archived deck should only be listed as archived, active: %v, archived: %v", len(active.Decks), len(archived.Decks))
+		}
+		if _, err := RestoreDeck(store, deckId); err != nil {
+			t.Errorf("Failed to restore deck: %v", err)
+		}
+		if _, err := store.DrawCardsFromDeck(deckId, 1); err != nil {
+			t.Errorf("A restored deck should be drawn from: %v", err)
+		}
+
+		if err := DeleteDeck(store, deckId); err != nil {
+			t.Errorf("Failed to delete deck: %v", err)
+		}
+		if _, err := OpenDeck(store, deckId); err != ErrDeckNotFound {
+			t.Errorf("A deleted deck should not be found, actual: %v", err)
+		}
+		if err := DeleteDeck(store, deckId); err != ErrDeckNotFound {
+			t.Errorf("A deleted deck should not be deleted again, actual: %v", err)
+		}
+	})
+	t.Run("Expired decks are gone", func(t *testing.T) {
+		expiresAt := time.Now().Add(-time.Second)
+		deck := Deck{DeckId: uuid.NewString(), Owner: uuid.NewString(), ExpiresAt: &expiresAt}
+		if _, err := store.InsertDeck(deck); err != nil {
+			t.Fatalf("Failed to insert deck: %v", err)
+		}
+		if _, err := OpenDeck(store, deck.DeckId); err != ErrDeckNotFound {
+			t.Errorf("An expired deck should not be found, actual: %v", err)
+		}
+		if _, err := store.UpdateDeck(deck.DeckId, func(deck *Deck) error { return nil }); err != ErrDeckNotFound {
+			t.Errorf("An expired deck should not be updated, actual: %v", err)
+		}
+		if page, _ := ListDecks(store, DeckFilter{Owner: deck.Owner}, "", MaxListLimit); len(page.Decks) != 0 {
+			t.Errorf("An expired deck should not be listed, actual: %v", page.Decks)
+		}
+	})
+	t.Run("Reserve idempotency key", func(t *testing.T) {
+		pending := StoredResponse{Id: uuid.NewString(), RequestHash: "first", ExpiresAt: time.Now().Add(time.Minute)}
+		if _, reserved, err := store.ReserveResponse(pending); err != nil || !reserved {
+			t.Fatalf("Failed to reserve a new key, reserved: %v, error: %v", reserved, err)
+		}
+		retry := pending
+		retry.RequestHash = "retry"
+		stored, reserved, err := store.ReserveResponse(retry)
+		if err != nil || reserved || stored.RequestHash != "first" {
+			t.Errorf("A reserved key should not be reserved again, reserved: %v, stored: %v, error: %v", reserved, stored, err)
+		}
+
+		done := pending
+		done.Status = 201
+		done.Body = []byte(`"done"`)
+		if err = store.SaveResponse(done); err != nil {
+			t.Errorf("Failed to save response: %v", err)
+		}
+		stored, _, _ = store.ReserveResponse(retry)
+		if stored.Status != 201 || string(stored.Body) != `"done"` {
+			t.Errorf("The saved response should be returned, actual: %v", stored)
+		}
+
+		expired := done
+		expired.ExpiresAt = time.Now().Add(-time.Second)
+		_ = store.SaveResponse(expired)
+		if _, reserved, err = store.ReserveResponse(retry); err != nil || !reserved {
+			t.Errorf("An expired key should be reserved again, reserved: %v, error: %v", reserved, err)
+		}
+		if err = store.DeleteResponse(pending.Id); err != nil {
+			t.Errorf("Failed to delete response: %v", err)
+		}
+		if _, reserved, _ = store.ReserveResponse(pending); !reserved {
+			t.Error("A deleted key should be reserved again")
+		}
+	})
+	t.Run("Play a hand at a table", func(t *testing.T) {
+		table, err := CreateTable(store, TableOptions{Seats: 4, Players: []string{"alice", "bob", "carol"}})
+		if err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		for _, phase := range []string{PhasePreflop, PhaseFlop, PhaseTurn, PhaseRiver, PhaseShowdown} {
+			if table, err = AdvanceTable(store, table.TableId); err != nil {
+				t.Fatalf("Failed to advance to %v: %v", phase, err)
+			}
+			stored, err := OpenTable(store, table.TableId)
+			if err != nil || table.Phase != phase || stored.Phase != phase || len(stored.Board) != len(table.Board) {
+				t.Fatalf("Table should be stored at %v, returned: %v, stored: %v, error: %v", phase, table.Phase, stored.Phase, err)
+			}
+		}
+		if table.Button != 1 || table.Hand != 1 || len(table.Board) != 5 || table.Showdown == nil || len(table.Showdown.Winners) == 0 {
+			t.Errorf("Hand was not played out, actual: %+v", table)
+		}
+
+		deck, err := OpenDeck(store, table.DeckId)
+		if err != nil {
+			t.Fatalf("Failed to open the table's deck: %v", err)
+		}
+		if !deck.Closed || len(deck.Piles[BurnPile]) != 3 || deck.Remaining != 52-3*2-3-5 {
+			t.Errorf("Deck should be closed with three burnt cards, closed: %v, piles: %v, remaining: %v", deck.Closed, deck.Piles, deck.Remaining)
+		}
+		seen := make(map[string]bool)
+		dealt := append([]Card(nil), table.Board...)
+		for _, seat := range table.Seats {
+			if seat.Player != "" && len(seat.Cards) != 2 || seat.Player == "" && len(seat.Cards) != 0 {
+				t.Errorf("Seat %v was dealt the wrong cards: %v", seat.Number, seat.Cards)
+			}
+			if !sameOrder(seat.Cards, canonicalCards(deck.Piles[seatPile(seat)])) {
+				t.Errorf("Seat %v holds cards that are not in its pile: %v", seat.Number, seat.Cards)
+			}
+			dealt = append(dealt, seat.Cards...)
+		}
+		for _, card := range dealt {
+			if seen[card.Code] {
+				t.Errorf("Card %v was dealt more than once", card.Code)
+			}
+			seen[card.Code] = true
+		}
+
+		if table, err = AdvanceTable(store, table.TableId); err != nil {
+			t.Fatalf("Failed to start the next hand: %v", err)
+		}
+		if table.Button != 2 || table.Hand != 2 || table.Phase != PhasePreflop || table.Board != nil || table.Showdown != nil || table.DeckId == deck.DeckId {
+			t.Errorf("Next hand should move the button and use a new deck, actual: %+v", table)
+		}
+	})
+	t.Run("Button skips empty seats", func(t *testing.T) {
+		table, _ := CreateTable(store, TableOptions{Seats: 4, Players: []string{"alice", "bob"}})
+		if _, err := SitDown(store, table.TableId, 4, "dave"); err != nil {
+			t.Fatalf("Failed to sit down: %v", err)
+		}
+		if _, err := StandUp(store, table.TableId, 2); err != nil {
+			t.Fatalf("Failed to stand up: %v", err)
+		}
+		var buttons []int
+		for i := 0; i < 3; i++ {
+			table, _ = AdvanceTable(store, table.TableId)
+			buttons = append(buttons, table.Button)
+			for table.Phase != PhaseShowdown {
+				table, _ = AdvanceTable(store, table.TableId)
+			}
+		}
+		if fmt.Sprint(buttons) != "[1 4 1]" {
+			t.Errorf("Button should move between seated players, expected: %v, actual: %v", "[1 4 1]", buttons)
+		}
+		if _, err := SitDown(store, table.TableId, 1, "erin"); err != ErrSeatTaken {
+			t.Errorf("A taken seat should not be sat in, actual: %v", err)
+		}
+		table, _ = AdvanceTable(store, table.TableId)
+		if _, err := SitDown(store, table.TableId, 2, "erin"); err != ErrHandInProgress {
+			t.Errorf("Nobody should sit down during a hand, actual: %v", err)
+		}
+		if _, err := StandUp(store, table.TableId, 1); err != ErrHandInProgress {
+			t.Errorf("Nobody should stand up during a hand, actual: %v", err)
+		}
+	})
+	t.Run("Seat token shows a player their own hole cards", func(t *testing.T) {
+		table, _ := CreateTable(store, TableOptions{Seats: 3, Players: []string{"alice", "bob"}})
+		seated, err := SitDown(store, table.TableId, 3, "carol")
+		if err != nil {
+			t.Fatalf("Failed to sit down: %v", err)
+		}
+		alice, carol := table.Seats[0].Token, seated.Seats[2].Token
+		if alice == "" || carol == "" || seated.Seats[0].Token != "" {
+			t.Fatalf("Seating should hand out a token for the new seat only, actual: %+v", seated.Seats)
+		}
+		if opened, _ := OpenTable(store, table.TableId); opened.Seats[0].Token != "" || opened.Seats[0].TokenHash == "" {
+			t.Errorf("Tables should keep only the hash of a seat token, actual: %+v", opened.Seats[0])
+		}
+		table, _ = AdvanceTable(store, table.TableId)
+
+		seat, err := SeatCards(store, table.TableId, 3, carol)
+		if err != nil || seat.Player != "carol" || !sameOrder(seat.Cards, table.Seats[2].Cards) {
+			t.Errorf("Seat token should show the seat's hole cards, actual: %+v, error: %v", seat, err)
+		}
+		tests := []struct {
+			seat     int
+			token    string
+			expected error
+		}{
+			{1, "", ErrSeatTokenRequired},
+			{1, carol, ErrSeatTokenInvalid},
+			{2, alice, ErrSeatTokenInvalid},
+		}
+		for _, tt := range tests {
+			if _, err := SeatCards(store, table.TableId, tt.seat, tt.token); err != tt.expected {
+				t.Errorf("Seat %v should not show its cards, expected: %v, actual: %v", tt.seat, tt.expected, err)
+			}
+		}
+	})
+	t.Run("Retried street reuses the cards already drawn", func(t *testing.T) {
+		table, _ := CreateTable(store, TableOptions{Players: []string{"alice", "bob"}})
+		table, _ = AdvanceTable(store, table.TableId)
+		// a request that drew the flop and failed before saving the table
+		deck, err := store.UpdateDeck(table.DeckId, func(deck *Deck) error {
+			if _, err := deck.Draw(DrawOptions{Count: 1, Pile: BurnPile}); err != nil {
+				return err
+			}
+			_, err := deck.Draw(DrawOptions{Count: 3, Pile: PhaseFlop})
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Failed to draw the flop: %v", err)
+		}
+
+		table, err = AdvanceTable(store, table.TableId)
+		if err != nil {
+			t.Fatalf("Failed to advance: %v", err)
+		}
+		if !sameOrder(table.Board, canonicalCards(deck.Piles[PhaseFlop])) {
+			t.Errorf("Flop should be the cards already drawn, expected: %v, actual: %v", deck.Piles[PhaseFlop], table.Board)
+		}
+		if deck, _ = OpenDeck(store, table.DeckId); len(deck.Piles[BurnPile]) != 1 || deck.Remaining != 52-4-4 {
+			t.Errorf("No more cards should be drawn, burnt: %v, remaining: %v", len(deck.Piles[BurnPile]), deck.Remaining)
+		}
+	})
+	t.Run("Concurrent advances never skip a phase", func(t *testing.T) {
+		table, _ := CreateTable(store, TableOptions{Players: []string{"alice", "bob"}})
+		table, _ = AdvanceTable(store, table.TableId)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := AdvanceTable(store, table.TableId); err != nil && err != ErrTableAdvanced {
+					t.Errorf("Advance should only fail because of another advance, actual: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		table, _ = OpenTable(store, table.TableId)
+		deck, _ := OpenDeck(store, table.DeckId)
+		var board []Card
+		for _, street := range []string{PhaseFlop, PhaseTurn, PhaseRiver} {
+			board = append(board, canonicalCards(deck.Piles[street])...)
+		}
+		if len(table.Board) > 5 || !sameOrder(table.Board, board[:len(table.Board)]) {
+			t.Errorf("Board should follow the deck's piles, board: %v, piles: %v", table.Board, board)
+		}
+	})
+	t.Run("Hand is called off when its deck is gone", func(t *testing.T) {
+		table, _ := CreateTable(store, TableOptions{Players: []string{"alice", "bob"}})
+		table, _ = AdvanceTable(store, table.TableId)
+		deck, err := OpenDeck(store, table.DeckId)
+		if err != nil || deck.TableId != table.TableId || deck.ExpiresAt == nil {
+			t.Fatalf("Hand deck should belong to the table and expire, deck: %+v, error: %v", deck, err)
+		}
+		if err = store.DeleteDeck(table.DeckId); err != nil {
+			t.Fatalf("Failed to delete the deck: %v", err)
+		}
+
+		table, err = AdvanceTable(store, table.TableId)
+		if err != nil || table.Phase != PhaseWaiting || table.DeckId != "" || len(table.Seats[0].Cards) != 0 {
+			t.Fatalf("Hand should be called off, table: %+v, error: %v", table, err)
+		}
+		if _, err = StandUp(store, table.TableId, 2); err != nil {
+			t.Errorf("Players should leave once the hand is called off, actual: %v", err)
+		}
+		if _, err = SitDown(store, table.TableId, 2, "bob"); err != nil {
+			t.Errorf("Players should sit down once the hand is called off, actual: %v", err)
+		}
+		if table, err = AdvanceTable(store, table.TableId); err != nil || table.Phase != PhasePreflop || table.Hand != 2 {
+			t.Errorf("Next advance should deal a new hand, table: %+v, error: %v", table, err)
+		}
+	})
+	t.Run("Open invalid table", func(t *testing.T) {
+		if _, err := OpenTable(store, uuid.NewString()); err != ErrTableNotFound {
+			t.Errorf("ErrTableNotFound is expected to return, actual: %v", err)
+		}
+		if _, err := AdvanceTable(store, uuid.NewString()); err != ErrTableNotFound {
+			t.Errorf("ErrTableNotFound is expected to return, actual: %v", err)
+		}
+	})
 	t.Run("Draw card with empty DeckID", func(t *testing.T) {
 		expected := 0
 		deckId := ""
-		SeedDb(uuid.NewString())
-		actual, err := DrawCardsFromDeck(deckId, 3)
+		SeedDb(store, uuid.NewString())
+		actual, err := store.DrawCardsFromDeck(deckId, 3)
 		if err == nil {
 			t.Errorf("An error is expected to return")
 		}
@@ -161,10 +804,29 @@ func TestDatabaseOperations(t *testing.T) {
 			t.Errorf("Cards drew should be empty, expected: %v, actual %v", expected, len(actual))
 		}
 	})
-	TeardownDb()
 }
 
-func SeedDb(deckId string) {
+func TestMemoryStoreSweep(t *testing.T) {
+	store := NewMemoryStore()
+	defer store.Close()
+	past := time.Now().Add(-time.Second)
+	future := time.Now().Add(time.Hour)
+	for _, deck := range []Deck{{DeckId: "expired", ExpiresAt: &past}, {DeckId: "alive", ExpiresAt: &future}, {DeckId: "forever"}} {
+		_, _ = store.InsertDeck(deck)
+	}
+	_ = store.SaveResponse(StoredResponse{Id: "expired", ExpiresAt: past})
+
+	store.sweep(time.Now())
+
+	if len(store.decks) != 2 || len(store.responses) != 0 {
+		t.Errorf("Only expired entries should be swept, decks: %v, responses: %v", len(store.decks), len(store.responses))
+	}
+	if _, exists := store.decks["expired"]; exists {
+		t.Error("The expired deck should be swept")
+	}
+}
+
+func SeedDb(store DeckStore, deckId string) {
 	decks := []Deck{
 		{
 			DeckId:    deckId,
@@ -184,21 +846,52 @@ func SeedDb(deckId string) {
 		},
 	}
 
-	var allDecks []interface{}
 	for _, d := range decks {
-		allDecks = append(allDecks, d)
+		_, _ = store.InsertDeck(d)
+	}
+}
+
+type namedStore struct {
+	name  string
+	store DeckStore
+}
+
+// testStores returns the in-memory store, plus the Mongo store configured in
+// test.env unless DECK_STORE=memory. A Mongo server that cannot be reached
+// fails the tests instead of skipping them, so the Mongo store is never left
+// untested by accident.
+func testStores(t *testing.T) []namedStore {
+	stores := []namedStore{{name: "memory", store: NewMemoryStore()}}
+	if os.Getenv("DECK_STORE") == "memory" {
+		t.Log("DECK_STORE=memory, not testing the MongoDB store")
+		return stores
+	}
+	mongoStore, err := NewMongoStore(
+		os.Getenv("MONGO_CONNECTION_STRING"),
+		os.Getenv("DB_NAME"),
+		os.Getenv("POKER_COLLECTION_NAME"),
+	)
+	if err != nil {
+		t.Fatalf("Failed to connect to MongoDB, set DECK_STORE=memory to test only the memory store: %v", err)
 	}
-	dbName := os.Getenv("DB_NAME")
-	collectionName := os.Getenv("POKER_COLLECTION_NAME")
-	coll := DbClient.Database(dbName).Collection(collectionName)
-	_, _ = coll.InsertMany(context.TODO(), allDecks)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := mongoStore.client.Ping(ctx, nil); err != nil {
+		_ = mongoStore.Close()
+		t.Fatalf("MongoDB is not reachable, set DECK_STORE=memory to test only the memory store: %v", err)
+	}
+	if err := mongoStore.EnsureIndexes(); err != nil {
+		t.Fatalf("Failed to create MongoDB indexes: %v", err)
+	}
+	return append(stores, namedStore{name: "mongo", store: mongoStore})
 }
 
-func TeardownDb() {
-	dbName := os.Getenv("DB_NAME")
-	collectionName := os.Getenv("POKER_COLLECTION_NAME")
-	coll := DbClient.Database(dbName).Collection(collectionName)
-	_, _ = coll.DeleteMany(context.TODO(), bson.D{})
-	_ = DbClient.Disconnect(context.TODO())
-	fmt.Println("closed connection to MongoDB")
+func TeardownDb(store DeckStore) {
+	if mongoStore, ok := store.(*MongoStore); ok {
+		_, _ = mongoStore.coll.DeleteMany(context.TODO(), bson.D{})
+		_, _ = mongoStore.responses.DeleteMany(context.TODO(), bson.D{})
+		_, _ = mongoStore.tables.DeleteMany(context.TODO(), bson.D{})
+		fmt.Println("closed connection to MongoDB")
+	}
+	_ = store.Close()
 }
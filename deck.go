@@ -1,70 +1,287 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"math/rand"
+	"strings"
 	"time"
 )
 
+// MaxDecksPerShoe is the largest shoe CreateDeck will build.
+const MaxDecksPerShoe = 8
+
+var ErrDeckClosed = errors.New("deck is closed")
+var ErrInsufficientCards = errors.New("deck has less cards than count intended to draw")
+var ErrInvalidCount = errors.New("invalid count")
+
 type Deck struct {
-	DeckId    string `json:"deck_id" bson:"_id"`
-	Shuffled  bool   `json:"shuffled" bson:"shuffled"`
-	Remaining int    `json:"remaining" bson:"remaining"`
-	Cards     []Card `json:"cards,omitempty" bson:"cards,omitempty"`
+	DeckId      string `json:"deck_id" bson:"_id"`
+	Shuffled    bool   `json:"shuffled" bson:"shuffled"`
+	Remaining   int    `json:"remaining" bson:"remaining"`
+	Composition string `json:"composition" bson:"composition,omitempty"`
+	Decks       int    `json:"decks,omitempty" bson:"decks,omitempty"`
+	Jokers      int    `json:"jokers,omitempty" bson:"jokers,omitempty"`
+	ShuffleMode string `json:"shuffle_mode,omitempty" bson:"shuffle_mode,omitempty"`
+	// Seed drives a seeded shuffle and is kept private, only its hash is served.
+	Seed           string   `json:"-" bson:"seed,omitempty"`
+	SeedHash       string   `json:"seed_hash,omitempty" bson:"seed_hash,omitempty"`
+	RequestedCards []string `json:"-" bson:"requested_cards,omitempty"`
+	// Commitment binds a shuffled deck to its order before any card is dealt,
+	// Salt and CommittedOrder stay private until the deck is revealed.
+	Commitment     string   `json:"commitment,omitempty" bson:"commitment,omitempty"`
+	Salt           string   `json:"-" bson:"salt,omitempty"`
+	CommittedOrder []string `json:"-" bson:"committed_order,omitempty"`
+	// PastCommitments are the commitments replaced when the deck was
+	// reordered, oldest first, private until the deck is revealed.
+	PastCommitments []PastCommitment `json:"-" bson:"past_commitments,omitempty"`
+	Closed          bool             `json:"closed,omitempty" bson:"closed,omitempty"`
+	Cards           []Card           `json:"cards,omitempty" bson:"cards,omitempty"`
+	// Piles are named stacks of cards that left the draw pile but stay with
+	// the deck, such as a discard pile or a player's hand.
+	Piles map[string][]Card `json:"piles,omitempty" bson:"piles,omitempty"`
+	// Drawn holds the cards dealt from the deck so they can be returned to it.
+	Drawn []Card `json:"-" bson:"drawn,omitempty"`
+	// Owner, Tags and Labels are chosen by whoever creates the deck, to find
+	// it again with ListDecks and to tie it to their own records.
+	Owner       string            `json:"owner,omitempty" bson:"owner,omitempty"`
+	Tags        []string          `json:"tags,omitempty" bson:"tags,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty" bson:"labels,omitempty"`
+	CreatedAt   time.Time         `json:"created_at" bson:"created_at,omitempty"`
+	UpdatedAt   time.Time         `json:"updated_at" bson:"updated_at,omitempty"`
+	LastDrawnAt *time.Time        `json:"last_drawn_at,omitempty" bson:"last_drawn_at,omitempty"`
+	// ExpiresAt is when a deck created with a time to live is removed.
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty" bson:"archived_at,omitempty"`
+	// TableId is the table dealing from the deck, only it may change the deck.
+	TableId string `json:"table_id,omitempty" bson:"table_id,omitempty"`
+	Version int64  `json:"-" bson:"version"`
+}
+
+// DeckOptions describes the deck CreateDeck should build.
+type DeckOptions struct {
+	Shuffled bool
+	// Decks is how many sets of the composition make up the shoe, zero means one.
+	Decks int
+	// Composition names the set of values in each deck, empty means standard.
+	Composition string
+	// Jokers is added to each deck of the shoe.
+	Jokers int
+	// Cards restricts the deck to these card codes when not empty.
+	Cards []string
+	// ShuffleMode picks the source of randomness, empty means seeded.
+	ShuffleMode string
+	// Seed makes a shuffled deck reproducible, a random one is picked when empty.
+	Seed   string
+	Owner  string
+	Tags   []string
+	Labels map[string]string
+	// TTL removes the deck that long after it is created, zero keeps it.
+	TTL time.Duration
+	// TableId hands the deck to the table that deals from it.
+	TableId string
+}
+
+func (opts DeckOptions) Validate() error {
+	if opts.Decks < 0 || opts.Decks > MaxDecksPerShoe {
+		return fmt.Errorf("decks must be between 1 and %d", MaxDecksPerShoe)
+	}
+	if opts.Jokers < 0 || opts.Jokers > MaxJokersPerDeck {
+		return fmt.Errorf("jokers must be between 0 and %d", MaxJokersPerDeck)
+	}
+	if opts.Seed != "" && !opts.Shuffled {
+		return errors.New("seed can only be used with shuffle")
+	}
+	if err := validateShuffleMode(opts.ShuffleMode); err != nil {
+		return err
+	}
+	if opts.ShuffleMode != "" && !opts.Shuffled {
+		return errors.New("shuffle_mode can only be used with shuffle")
+	}
+	if opts.Seed != "" && opts.ShuffleMode == ShuffleModeSecure {
+		return errors.New("seed cannot be used with a secure shuffle")
+	}
+	if _, err := LookupComposition(opts.Composition); err != nil {
+		return err
+	}
+	if len(opts.Cards) > 0 {
+		if _, err := ParseCardCodes(strings.Join(opts.Cards, ","), opts.CardCodes()); err != nil {
+			return err
+		}
+	}
+	if opts.TTL < 0 {
+		return errors.New("ttl must be positive")
+	}
+	if len(opts.Owner) > MaxOwnerLength {
+		return fmt.Errorf("owner must not be longer than %d characters", MaxOwnerLength)
+	}
+	if len(opts.Tags) > MaxTagsPerDeck {
+		return fmt.Errorf("a deck can have at most %d tags", MaxTagsPerDeck)
+	}
+	for _, tag := range opts.Tags {
+		if err := ValidateTag(tag); err != nil {
+			return err
+		}
+	}
+	return ValidateLabels(opts.Labels)
 }
 
+// timestamp is the current time the way every store keeps it, in UTC and to
+// the millisecond as Mongo does.
+func timestamp() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// CardCodes is the set of codes a deck built from opts can contain.
+func (opts DeckOptions) CardCodes() map[string]bool {
+	deck := Deck{Composition: opts.Composition, Jokers: opts.Jokers}
+	deck.GenerateCards(false)
+	codes := make(map[string]bool)
+	for _, card := range deck.Cards {
+		codes[card.Code] = true
+	}
+	return codes
+}
+
+// GenerateCards fills the deck with d.Decks sets of d.Composition plus
+// d.Jokers jokers each. Whenever the same code occurs more than once, as in a
+// shoe or a pinochle deck, every card records which copy it is so two cards
+// with the same code can still be told apart.
+//
+// A seeded shuffle uses its own source seeded from d.Seed, so the same seed
+// and options always produce the same order. A secure shuffle uses crypto/rand
+// and records no seed.
 func (d *Deck) GenerateCards(shuffle bool, requestedCards ...string) {
 
-	allValues := []string{"ACE", "2", "3", "4", "5", "6", "7", "8", "9", "10", "JACK", "QUEEN", "KING"}
+	composition, err := LookupComposition(d.Composition)
+	if err != nil {
+		composition, _ = LookupComposition(StandardComposition)
+	}
 	allSuits := []string{"SPADES", "DIAMONDS", "CLUBS", "HEARTS"}
 	var allCards []Card
 
-	for _, suit := range allSuits {
-		for _, value := range allValues {
-			firstCharValueRune := []rune(value)
-			firstCharValue := string(firstCharValueRune[0:1])
-			firstCharSuitRune := []rune(suit)
-			firstCharSuit := string(firstCharSuitRune[0:1])
-			cardCode := firstCharValue + firstCharSuit
-			allCards = append(allCards, Card{
-				Value: value,
-				Suit:  suit,
-				Code:  cardCode,
-			})
+	decks := d.Decks
+	if decks < 1 {
+		decks = 1
+	}
+	for i := 0; i < decks; i++ {
+		for _, suit := range allSuits {
+			for _, value := range composition.Values {
+				for c := 0; c < composition.Copies; c++ {
+					allCards = append(allCards, NewCard(value, suit))
+				}
+			}
 		}
+		allCards = append(allCards, jokers(d.Jokers)...)
 	}
 
-	// shuffle the card slice if shuffle is true
-	if shuffle {
-		rand.Seed(time.Now().UnixNano())
-		rand.Shuffle(len(allCards), func(i, j int) {
-			allCards[i], allCards[j] = allCards[j], allCards[i]
-		})
+	if decks > 1 || composition.Copies > 1 {
+		copies := make(map[string]int)
+		for i := range allCards {
+			copies[allCards[i].Code]++
+			allCards[i].Copy = copies[allCards[i].Code]
+		}
 	}
 
-	// returns only requested cards
+	// keeps only requested cards, in the order they were requested
 	if len(requestedCards) > 0 {
 		var partialCards []Card
 		for _, requestedCard := range requestedCards {
+			// a shoe contributes every copy of a requested card
 			for i, card := range allCards {
 				if requestedCard == card.Code {
 					partialCards = append(partialCards, allCards[i])
-					break
 				}
 			}
 		}
+		allCards = partialCards
+	}
 
-		d.Cards = partialCards
-	} else {
-		d.Cards = allCards
+	// shuffle the card slice if shuffle is true
+	if shuffle {
+		swap := func(i, j int) {
+			allCards[i], allCards[j] = allCards[j], allCards[i]
+		}
+		if d.ShuffleMode == ShuffleModeSecure {
+			secureShuffle(len(allCards), swap)
+		} else {
+			if d.Seed == "" {
+				d.Seed = NewSeed()
+			}
+			d.SeedHash = HashSeed(d.Seed)
+			rng := rand.New(rand.NewSource(seedSource(d.Seed)))
+			rng.Shuffle(len(allCards), swap)
+		}
+	}
+
+	d.Cards = allCards
+}
+
+// PopCards removes count cards from the top (the end) of the deck and keeps
+// Remaining in step with what is left.
+func (d *Deck) PopCards(count int) ([]Card, error) {
+	if d.Closed {
+		return nil, ErrDeckClosed
 	}
+	left, cards, err := popTop(d.Cards, count)
+	if err != nil {
+		return nil, err
+	}
+	d.Cards = left
+	d.Drawn = append(d.Drawn, cards...)
+	d.Remaining = len(d.Cards)
+	now := timestamp()
+	d.LastDrawnAt = &now
+	return cards, nil
 }
 
-func CreateDeck(shuffled bool, requestedCards ...string) (Deck, error) {
-	deck := Deck{DeckId: uuid.NewString(), Shuffled: shuffled}
-	deck.GenerateCards(shuffled, requestedCards...)
+func CreateDeck(store DeckStore, opts DeckOptions) (Deck, error) {
+	if err := opts.Validate(); err != nil {
+		return Deck{}, err
+	}
+	composition, _ := LookupComposition(opts.Composition)
+	deck := Deck{
+		DeckId:      uuid.NewString(),
+		Shuffled:    opts.Shuffled,
+		Composition: composition.Name,
+		Jokers:      opts.Jokers,
+		Seed:        opts.Seed,
+		Owner:       opts.Owner,
+		Tags:        opts.Tags,
+		Labels:      opts.Labels,
+		TableId:     opts.TableId,
+		CreatedAt:   timestamp(),
+	}
+	deck.UpdatedAt = deck.CreatedAt
+	if opts.TTL > 0 {
+		expiresAt := deck.CreatedAt.Add(opts.TTL)
+		deck.ExpiresAt = &expiresAt
+	}
+	if opts.Shuffled {
+		deck.ShuffleMode = ShuffleModeSeeded
+		if opts.ShuffleMode != "" {
+			deck.ShuffleMode = opts.ShuffleMode
+		}
+	}
+	if opts.Decks > 1 {
+		deck.Decks = opts.Decks
+	}
+	if len(opts.Cards) > 0 {
+		// store canonical codes, GenerateCards and replays match them exactly
+		cards, err := ParseCardCodes(strings.Join(opts.Cards, ","), opts.CardCodes())
+		if err != nil {
+			return Deck{}, err
+		}
+		opts.Cards = cards
+	}
+	deck.RequestedCards = opts.Cards
+	deck.GenerateCards(opts.Shuffled, opts.Cards...)
 	deck.Remaining = len(deck.Cards)
-	_, err := InsertDeck(deck)
+	if deck.Shuffled {
+		deck.commit()
+	}
+	_, err := store.InsertDeck(deck)
 	deck.Cards = nil
 	if err != nil {
 		return deck, err
@@ -72,18 +289,62 @@ func CreateDeck(shuffled bool, requestedCards ...string) (Deck, error) {
 	return deck, nil
 }
 
-func OpenDeck(deckId string) (Deck, error) {
-	od, err := GetDeck(deckId)
+func OpenDeck(store DeckStore, deckId string) (Deck, error) {
+	od, err := store.GetDeck(deckId)
 	if err != nil {
 		return od, err
 	}
+	// decks created before compositions existed are always standard
+	if od.Composition == "" {
+		od.Composition = StandardComposition
+	}
+	for i := range od.Cards {
+		od.Cards[i] = od.Cards[i].Canonical()
+	}
 	return od, nil
 }
 
-func DrawCards(deckId string, count int) ([]Card, error) {
-	cards, err := DrawCardsFromDeck(deckId, count)
+// Conceal hides the cards still in the deck and in its piles, which would tell
+// what is dealt next or what other players hold.
+func (d *Deck) Conceal() {
+	d.Cards = nil
+	d.Piles = nil
+}
+
+// OriginalCards rebuilds the order the deck had when it was created, before
+// any card was drawn.
+func (d Deck) OriginalCards() ([]Card, error) {
+	if d.ShuffleMode == ShuffleModeSecure {
+		return nil, errors.New("a secure shuffle cannot be replayed")
+	}
+	if d.Shuffled && d.Seed == "" {
+		return nil, errors.New("deck has no recorded seed")
+	}
+	original := Deck{
+		Composition: d.Composition,
+		Decks:       d.Decks,
+		Jokers:      d.Jokers,
+		Seed:        d.Seed,
+	}
+	original.GenerateCards(d.Shuffled, d.RequestedCards...)
+	return original.Cards, nil
+}
+
+// ReplayDeck recreates the original order of a deck for an audit. The caller
+// must know the seed, which is checked against the hash stored on the deck,
+// until the deck is closed or exhausted and its seed is disclosed anyway.
+func ReplayDeck(store DeckStore, deckId string, seed string) ([]Card, error) {
+	deck, err := store.GetDeck(deckId)
 	if err != nil {
 		return nil, err
 	}
-	return cards, nil
+	if deck.Shuffled && deck.SeedHash != "" {
+		if seed == "" && !deck.revealable() {
+			return nil, errors.New("seed is required until the deck is closed or exhausted")
+		}
+		if seed != "" && HashSeed(seed) != deck.SeedHash {
+			return nil, errors.New("seed does not match the deck")
+		}
+	}
+	return deck.OriginalCards()
 }
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Where in the deck DrawCards takes cards from.
+const (
+	DrawTop    = "top"
+	DrawBottom = "bottom"
+	DrawRandom = "random"
+	DrawAt     = "at"
+)
+
+// DrawOptions describes which cards DrawCards takes from the deck.
+type DrawOptions struct {
+	Count    int
+	Position string
+	// Index is how many cards down from the top an "at" draw starts, 0 being the top card.
+	Index int
+	// Codes draws exactly these cards instead, wherever they are in the deck.
+	Codes []Card
+	// Pile, when set, receives the drawn cards instead of the caller's hand.
+	Pile string
+}
+
+func (opts DrawOptions) Validate() error {
+	if opts.Pile != "" {
+		if err := ValidatePileName(opts.Pile); err != nil {
+			return err
+		}
+	}
+	if len(opts.Codes) > 0 {
+		if opts.Count != len(opts.Codes) {
+			return fmt.Errorf("%w: must match the %d requested codes", ErrInvalidCount, len(opts.Codes))
+		}
+		if opts.Position != "" && opts.Position != DrawTop {
+			return errors.New("codes cannot be combined with a position")
+		}
+		return nil
+	}
+	if opts.Count < 1 {
+		return fmt.Errorf("%w: must be greater than zero", ErrInvalidCount)
+	}
+	switch opts.Position {
+	case "", DrawTop, DrawBottom, DrawRandom:
+	case DrawAt:
+		if opts.Index < 0 {
+			return errors.New("index must not be negative")
+		}
+	default:
+		return fmt.Errorf("unknown position %q, expected one of %q, %q, %q or %q",
+			opts.Position, DrawTop, DrawBottom, DrawRandom, DrawAt)
+	}
+	return nil
+}
+
+// belongs is the set of codes of every card the deck owns, wherever it is.
+func (d *Deck) belongs() map[string]bool {
+	belongs := make(map[string]bool)
+	for _, card := range d.Cards {
+		belongs[card.Canonical().Code] = true
+	}
+	for _, card := range d.Drawn {
+		belongs[card.Canonical().Code] = true
+	}
+	for _, pile := range d.Piles {
+		for _, card := range pile {
+			belongs[card.Canonical().Code] = true
+		}
+	}
+	return belongs
+}
+
+// Draw removes cards from the deck as opts describes and records them as
+// drawn, or puts them on top of opts.Pile. Requested codes that never belonged
+// to the deck are reported as NotInDeck and codes that are no longer in the
+// draw pile as Missing, in a *CardCodeError.
+func (d *Deck) Draw(opts DrawOptions) ([]Card, error) {
+	if d.Closed {
+		return nil, ErrDeckClosed
+	}
+	if opts.Pile == "" && len(opts.Codes) == 0 && (opts.Position == "" || opts.Position == DrawTop) {
+		return d.PopCards(opts.Count)
+	}
+	if len(opts.Codes) == 0 && len(d.Cards) < opts.Count {
+		return nil, ErrInsufficientCards
+	}
+
+	var cards []Card
+	switch {
+	case len(opts.Codes) > 0:
+		var err error
+		d.Cards, cards, err = takeCodes(d.Cards, opts.Codes)
+		var codeErr *CardCodeError
+		if errors.As(err, &codeErr) {
+			belongs := d.belongs()
+			missing := codeErr.Missing
+			codeErr.Missing = nil
+			for _, code := range missing {
+				if belongs[code] {
+					codeErr.Missing = append(codeErr.Missing, code)
+				} else {
+					codeErr.NotInDeck = append(codeErr.NotInDeck, code)
+				}
+			}
+			return nil, codeErr
+		}
+	case opts.Position == "" || opts.Position == DrawTop:
+		d.Cards, cards, _ = popTop(d.Cards, opts.Count)
+	case opts.Position == DrawBottom:
+		cards = append(cards, d.Cards[:opts.Count]...)
+		d.Cards = append([]Card(nil), d.Cards[opts.Count:]...)
+	case opts.Position == DrawRandom:
+		for i := 0; i < opts.Count; i++ {
+			j := secureIntn(len(d.Cards))
+			cards = append(cards, d.Cards[j])
+			d.Cards = append(d.Cards[:j], d.Cards[j+1:]...)
+		}
+	case opts.Position == DrawAt:
+		// compared this way round so a huge index cannot overflow the sum
+		if opts.Index >= len(d.Cards) || opts.Count > len(d.Cards)-opts.Index {
+			return nil, fmt.Errorf("%w from index %d", ErrInsufficientCards, opts.Index)
+		}
+		// the top is the end of the slice, so index counts back from there
+		start := len(d.Cards) - opts.Index
+		for i := start - 1; i >= start-opts.Count; i-- {
+			cards = append(cards, d.Cards[i])
+		}
+		d.Cards = append(d.Cards[:start-opts.Count], d.Cards[start:]...)
+	}
+	if opts.Pile != "" {
+		pile, _ := d.source(opts.Pile)
+		d.setSource(opts.Pile, append(append([]Card(nil), pile...), cards...))
+	} else {
+		d.Drawn = append(d.Drawn, cards...)
+	}
+	d.Remaining = len(d.Cards)
+	now := timestamp()
+	d.LastDrawnAt = &now
+	return cards, nil
+}
+
+func DrawCards(store DeckStore, deckId string, opts DrawOptions) ([]Card, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	var cards []Card
+	var err error
+	if opts.Pile == "" && len(opts.Codes) == 0 && (opts.Position == "" || opts.Position == DrawTop) {
+		cards, err = store.DrawCardsFromDeck(deckId, opts.Count)
+	} else {
+		_, err = store.UpdateDeck(deckId, func(deck *Deck) error {
+			var err error
+			cards, err = deck.Draw(opts)
+			return err
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range cards {
+		cards[i] = cards[i].Canonical()
+	}
+	return cards, nil
+}
+
+// PeekCards returns the top count cards in the order DrawCards would return
+// them, leaving the deck untouched.
+func PeekCards(store DeckStore, deckId string, count int) ([]Card, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("%w: must be greater than zero", ErrInvalidCount)
+	}
+	deck, err := OpenDeck(store, deckId)
+	if err != nil {
+		return nil, err
+	}
+	_, cards, err := popTop(deck.Cards, count)
+	if err != nil {
+		return nil, err
+	}
+	return cards, nil
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"card-game/hand"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// How CalculateEquity arrived at its numbers.
+const (
+	EquityExact      = "exact"
+	EquityMonteCarlo = "monte_carlo"
+)
+
+const (
+	DefaultEquityIterations = 100000
+	MaxEquityIterations     = 2000000
+	DefaultEquityBudget     = time.Second
+	MaxEquityBudget         = 10 * time.Second
+	// MaxEquityPlayers is as many players as a table seats.
+	MaxEquityPlayers = MaxSeats
+	// exactEquityLimit is the most deals CalculateEquity enumerates before it
+	// samples instead. It covers every heads-up spot from the flop on.
+	exactEquityLimit = 250000
+)
+
+// EquityPlayer is a player in a Hold'em equity calculation, holding either
+// known Cards or any of the hole cards in Range.
+type EquityPlayer struct {
+	Id    string   `json:"id"`
+	Cards []string `json:"cards,omitempty"`
+	// Range lists the hole cards the player may hold in range notation, see
+	// hand.ParseRange. Combos that share a card with the board or with known
+	// hole cards are left out.
+	Range string `json:"range,omitempty"`
+}
+
+// EquityOptions describes a Hold'em spot to calculate equity for.
+type EquityOptions struct {
+	// Board holds the community cards dealt so far, up to five.
+	Board   []string
+	Players []EquityPlayer
+	// Iterations caps the deals a Monte Carlo run samples.
+	Iterations int
+	// Budget caps how long a Monte Carlo run samples for.
+	Budget time.Duration
+	// Seed makes a Monte Carlo run reproducible, a random one is picked when
+	// empty. A run stopped by Budget rather than Iterations may still differ.
+	Seed string
+}
+
+func (opts EquityOptions) Validate() error {
+	if len(opts.Board) > 5 {
+		return errors.New("the board has at most 5 cards")
+	}
+	if len(opts.Players) < 2 {
+		return errors.New("equity needs at least two players")
+	}
+	if len(opts.Players) > MaxEquityPlayers {
+		return fmt.Errorf("equity takes at most %d players", MaxEquityPlayers)
+	}
+	ids := make(map[string]bool)
+	for _, player := range opts.Players {
+		if player.Id == "" {
+			return errors.New("every player needs an id")
+		}
+		if ids[player.Id] {
+			return fmt.Errorf("player %q is listed more than once", player.Id)
+		}
+		ids[player.Id] = true
+		if (len(player.Cards) == 0) == (player.Range == "") {
+			return fmt.Errorf("player %q needs either cards or a range", player.Id)
+		}
+		if len(player.Cards) > 0 && len(player.Cards) != 2 {
+			return fmt.Errorf("%w: player %q must hold 2 cards", ErrInvalidCount, player.Id)
+		}
+	}
+	if opts.Iterations < 0 || opts.Iterations > MaxEquityIterations {
+		return fmt.Errorf("iterations must not be negative or above %d", MaxEquityIterations)
+	}
+	if opts.Budget < 0 || opts.Budget > MaxEquityBudget {
+		return fmt.Errorf("budget must not be negative or above %s", MaxEquityBudget)
+	}
+	return nil
+}
+
+// PlayerEquity is how often a player wins, ties and loses, in percent.
+// Equity is the share of the pot the player wins on average, counting a pot
+// split n ways as 1/n of a win.
+type PlayerEquity struct {
+	Player string  `json:"player"`
+	Win    float64 `json:"win"`
+	Tie    float64 `json:"tie"`
+	Lose   float64 `json:"lose"`
+	Equity float64 `json:"equity"`
+}
+
+type EquityResult struct {
+	Method string `json:"method"`
+	// Trials is how many deals were enumerated or sampled.
+	Trials  int            `json:"trials"`
+	Seed    string         `json:"seed,omitempty"`
+	Players []PlayerEquity `json:"players"`
+}
+
+// holding is one set of hole cards a player may hold.
+type holding struct {
+	cards  [2]hand.Card
+	mask   uint64
+	weight float64
+}
+
+// equityRun accumulates the outcome of every deal played out.
+type equityRun struct {
+	holdings [][]holding
+	// cumulative holds the running weight total of each player's holdings
+	cumulative [][]float64
+	board      []hand.Card
+	// remaining are the cards neither on the board nor known to be held
+	remaining []hand.Card
+	trials    int
+	total     float64
+	win       []float64
+	tie       []float64
+	lose      []float64
+	equity    []float64
+	// ranged counts the players holding a range rather than known cards
+	ranged int
+	dealt  []holding
+	ranks  []hand.Rank
+	cards  [7]hand.Card
+}
+
+// CalculateEquity plays out a Hold'em spot for every player. It enumerates
+// every possible deal when there are few enough and samples deals with a
+// seeded Monte Carlo run otherwise.
+func CalculateEquity(opts EquityOptions) (EquityResult, error) {
+	if err := opts.Validate(); err != nil {
+		return EquityResult{}, err
+	}
+	run, err := newEquityRun(opts)
+	if err != nil {
+		return EquityResult{}, err
+	}
+
+	deals := run.deals()
+	if deals == 0 {
+		return EquityResult{}, errors.New("the ranges leave no possible deal")
+	}
+	iterations, budget := opts.Iterations, opts.Budget
+	if iterations == 0 {
+		iterations = DefaultEquityIterations
+	}
+	if budget == 0 {
+		budget = DefaultEquityBudget
+	}
+
+	result := EquityResult{Method: EquityExact}
+	if deals <= exactEquityLimit {
+		if err := run.enumerate(budget); err != nil {
+			return EquityResult{}, err
+		}
+	} else {
+		result.Method = EquityMonteCarlo
+		result.Seed = opts.Seed
+		if result.Seed == "" {
+			result.Seed = NewSeed()
+		}
+		if err := run.sample(rand.New(rand.NewSource(seedSource(result.Seed))), iterations, budget); err != nil {
+			return EquityResult{}, err
+		}
+	}
+	if run.trials == 0 {
+		return EquityResult{}, errors.New("the ranges leave no possible deal")
+	}
+
+	result.Trials = run.trials
+	for i, player := range opts.Players {
+		result.Players = append(result.Players, PlayerEquity{
+			Player: player.Id,
+			Win:    percent(run.win[i], run.total),
+			Tie:    percent(run.tie[i], run.total),
+			Lose:   percent(run.lose[i], run.total),
+			Equity: percent(run.equity[i], run.total),
+		})
+	}
+	return result, nil
+}
+
+func newEquityRun(opts EquityOptions) (*equityRun, error) {
+	// every known card must be distinct, so check them in one go
+	known := append([]string(nil), opts.Board...)
+	for _, player := range opts.Players {
+		known = append(known, player.Cards...)
+	}
+	// a preflop spot between ranges knows no cards at all
+	var knownCards []hand.Card
+	if len(known) > 0 {
+		canonical, err := ParseCardCodes(strings.Join(known, ","), DeckOptions{}.CardCodes())
+		if err != nil {
+			return nil, err
+		}
+		if knownCards, err = hand.ParseCards(canonical...); err != nil {
+			return nil, err
+		}
+	}
+	var dead uint64
+	for _, card := range knownCards {
+		dead |= 1 << card
+	}
+
+	run := &equityRun{board: knownCards[:len(opts.Board)]}
+	next := len(opts.Board)
+	for _, player := range opts.Players {
+		var holdings []holding
+		if len(player.Cards) > 0 {
+			cards := [2]hand.Card{knownCards[next], knownCards[next+1]}
+			next += 2
+			holdings = []holding{{cards: cards, mask: 1<<cards[0] | 1<<cards[1], weight: 1}}
+		} else {
+			run.ranged++
+			r, err := hand.ParseRange(player.Range)
+			if err != nil {
+				return nil, err
+			}
+			for _, combo := range r.Without(knownCards...) {
+				holdings = append(holdings, holding{
+					cards:  combo.Cards,
+					mask:   1<<combo.Cards[0] | 1<<combo.Cards[1],
+					weight: combo.Weight,
+				})
+			}
+			if len(holdings) == 0 {
+				return nil, fmt.Errorf("every combo in the range of player %q is blocked by known cards", player.Id)
+			}
+		}
+		run.holdings = append(run.holdings, holdings)
+	}
+
+	deck := Deck{}
+	deck.GenerateCards(false)
+	for _, card := range deck.Cards {
+		c, _ := hand.ParseCard(card.Code)
+		if dead&(1<<c) == 0 {
+			run.remaining = append(run.remaining, c)
+		}
+	}
+
+	n := len(opts.Players)
+	run.win, run.tie, run.lose, run.equity = make([]float64, n), make([]float64, n), make([]float64, n), make([]float64, n)
+	run.ranks, run.dealt = make([]hand.Rank, n), make([]holding, n)
+	for _, holdings := range run.holdings {
+		cumulative := make([]float64, len(holdings))
+		total := 0.0
+		for i, h := range holdings {
+			total += h.weight
+			cumulative[i] = total
+		}
+		run.cumulative = append(run.cumulative, cumulative)
+	}
+	return run, nil
+}
+
+// deals estimates how many deals enumerate would play out, ignoring
+// holdings that collide with each other.
+func (run *equityRun) deals() float64 {
+	deals := 1.0
+	for _, holdings := range run.holdings {
+		deals *= float64(len(holdings))
+	}
+	return deals * binomial(len(run.remaining)-2*run.ranged, 5-len(run.board))
+}
+
+// enumerate plays out every combination of holdings and board cards, each
+// weighted by the weights of the holdings dealt. It gives up once budget has
+// passed, as a partial enumeration would skew the result.
+func (run *equityRun) enumerate(budget time.Duration) error {
+	start := time.Now()
+	expired := false
+	dealt := make([]holding, len(run.holdings))
+	board := make([]hand.Card, len(run.board), 5)
+	copy(board, run.board)
+	var deal func(player int, used uint64, weight float64)
+	deal = func(player int, used uint64, weight float64) {
+		if expired {
+			return
+		}
+		if player == len(run.holdings) {
+			var available []hand.Card
+			for _, card := range run.remaining {
+				if used&(1<<card) == 0 {
+					available = append(available, card)
+				}
+			}
+			forEachCombination(available, 5-len(run.board), func(extra []hand.Card) bool {
+				if run.trials%1000 == 0 && time.Since(start) > budget {
+					expired = true
+					return false
+				}
+				run.score(append(board[:len(run.board)], extra...), dealt, weight)
+				return true
+			})
+			return
+		}
+		for _, h := range run.holdings[player] {
+			if used&h.mask == 0 {
+				dealt[player] = h
+				deal(player+1, used|h.mask, weight*h.weight)
+			}
+		}
+	}
+	deal(0, 0, 1)
+	if expired {
+		return fmt.Errorf("playing out every deal took longer than the %s budget", budget)
+	}
+	return nil
+}
+
+// sample plays out random deals until iterations deals were played or budget
+// has passed. Holdings are picked in proportion to their weight and picked
+// again whenever two players would hold the same card.
+func (run *equityRun) sample(rng *rand.Rand, iterations int, budget time.Duration) error {
+	start := time.Now()
+	board := make([]hand.Card, 5)
+	copy(board, run.board)
+	available := make([]hand.Card, 0, len(run.remaining))
+	for run.trials < iterations {
+		if run.trials%1000 == 0 && time.Since(start) > budget {
+			break
+		}
+		used, dealt := run.pick(rng)
+		for attempt := 1; used == 0; attempt++ {
+			if attempt == 1000 {
+				return errors.New("the ranges leave no possible deal")
+			}
+			used, dealt = run.pick(rng)
+		}
+
+		available = available[:0]
+		for _, card := range run.remaining {
+			if used&(1<<card) == 0 {
+				available = append(available, card)
+			}
+		}
+		// a partial Fisher-Yates shuffle deals the rest of the board
+		for i := len(run.board); i < 5; i++ {
+			j := i - len(run.board) + rng.Intn(len(available)-(i-len(run.board)))
+			k := i - len(run.board)
+			available[k], available[j] = available[j], available[k]
+			board[i] = available[k]
+		}
+		run.score(board, dealt, 1)
+	}
+	return nil
+}
+
+// pick deals every player a holding in proportion to its weight and returns
+// the cards dealt, or 0 when two players were dealt the same card.
+func (run *equityRun) pick(rng *rand.Rand) (uint64, []holding) {
+	var used uint64
+	for player, holdings := range run.holdings {
+		cumulative := run.cumulative[player]
+		i := sort.SearchFloat64s(cumulative, rng.Float64()*cumulative[len(cumulative)-1])
+		if i == len(holdings) {
+			i--
+		}
+		if used&holdings[i].mask != 0 {
+			return 0, nil
+		}
+		run.dealt[player] = holdings[i]
+		used |= holdings[i].mask
+	}
+	return used, run.dealt
+}
+
+// score plays out one complete deal and records who won it.
+func (run *equityRun) score(board []hand.Card, dealt []holding, weight float64) {
+	n := copy(run.cards[:], board)
+	best, winners := hand.Rank(0), 0
+	for player, h := range dealt {
+		run.cards[n], run.cards[n+1] = h.cards[0], h.cards[1]
+		rank := hand.RankOf(run.cards[:n+2])
+		run.ranks[player] = rank
+		if rank > best {
+			best, winners = rank, 1
+		} else if rank == best {
+			winners++
+		}
+	}
+	run.trials++
+	run.total += weight
+	for player, rank := range run.ranks {
+		switch {
+		case rank != best:
+			run.lose[player] += weight
+		case winners == 1:
+			run.win[player] += weight
+			run.equity[player] += weight
+		default:
+			run.tie[player] += weight
+			run.equity[player] += weight / float64(winners)
+		}
+	}
+}
+
+// forEachCombination calls fn with every k card combination of cards until fn
+// returns false. fn must not keep the slice it is given.
+func forEachCombination(cards []hand.Card, k int, fn func([]hand.Card) bool) {
+	combination := make([]hand.Card, k)
+	var choose func(start, depth int) bool
+	choose = func(start, depth int) bool {
+		if depth == k {
+			return fn(combination)
+		}
+		for i := start; i <= len(cards)-(k-depth); i++ {
+			combination[depth] = cards[i]
+			if !choose(i+1, depth+1) {
+				return false
+			}
+		}
+		return true
+	}
+	choose(0, 0)
+}
+
+func binomial(n, k int) float64 {
+	if k < 0 || k > n {
+		return 0
+	}
+	result := 1.0
+	for i := 0; i < k; i++ {
+		result = result * float64(n-i) / float64(i+1)
+	}
+	return result
+}
+
+// percent returns part of total as a percentage rounded to two decimals.
+func percent(part, total float64) float64 {
+	return math.Round(part/total*10000) / 100
+}
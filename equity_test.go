@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestCalculateEquity(t *testing.T) {
+	t.Run("Complete board has one outcome", func(t *testing.T) {
+		actual, err := CalculateEquity(EquityOptions{
+			Board: []string{"2S", "7S", "QC", "9D", "3H"},
+			Players: []EquityPlayer{
+				{Id: "hero", Cards: []string{"AS", "AD"}},
+				{Id: "villain", Cards: []string{"KS", "KD"}},
+				{Id: "other", Cards: []string{"AH", "AC"}},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []PlayerEquity{
+			{Player: "hero", Tie: 100, Equity: 50},
+			{Player: "villain", Lose: 100},
+			{Player: "other", Tie: 100, Equity: 50},
+		}
+		if actual.Method != EquityExact || actual.Trials != 1 || !sameEquities(actual.Players, expected) {
+			t.Errorf("Equity is incorrect, expected: %v, actual: %v", expected, actual)
+		}
+	})
+	t.Run("Flop is enumerated exactly", func(t *testing.T) {
+		actual, err := CalculateEquity(EquityOptions{
+			Board: []string{"2S", "7S", "QC"},
+			Players: []EquityPlayer{
+				{Id: "hero", Cards: []string{"AS", "KS"}},
+				{Id: "villain", Cards: []string{"QH", "QD"}},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 45 cards are left for the turn and river
+		if actual.Method != EquityExact || actual.Trials != 990 {
+			t.Errorf("Flop should be enumerated, actual: %v", actual)
+		}
+		hero, villain := actual.Players[0], actual.Players[1]
+		if hero.Win != villain.Lose || hero.Tie != villain.Tie || math.Abs(hero.Win+hero.Tie+hero.Lose-100) > 0.02 {
+			t.Errorf("Equities do not add up, actual: %v", actual.Players)
+		}
+	})
+	t.Run("Preflop is sampled reproducibly", func(t *testing.T) {
+		opts := EquityOptions{
+			Players: []EquityPlayer{
+				{Id: "hero", Cards: []string{"AS", "AH"}},
+				{Id: "villain", Cards: []string{"KS", "KH"}},
+			},
+			Iterations: 50000,
+			Budget:     MaxEquityBudget,
+			Seed:       "coaching",
+		}
+		first, err := CalculateEquity(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, _ := CalculateEquity(opts)
+		if first.Method != EquityMonteCarlo || first.Trials != 50000 || first.Seed != "coaching" {
+			t.Errorf("Preflop should be sampled, actual: %v", first)
+		}
+		if !sameEquities(first.Players, second.Players) {
+			t.Errorf("Same seed should give the same equity, first: %v, second: %v", first.Players, second.Players)
+		}
+		// aces are about an 82% favourite over kings
+		if equity := first.Players[0].Equity; equity < 80.5 || equity > 83.5 {
+			t.Errorf("Equity of aces against kings is incorrect, actual: %v", equity)
+		}
+	})
+	t.Run("Sampling agrees with enumeration", func(t *testing.T) {
+		opts := EquityOptions{
+			Board: []string{"8H", "9H", "2C"},
+			Players: []EquityPlayer{
+				{Id: "hero", Cards: []string{"TH", "JH"}},
+				{Id: "villain", Range: "8s8d,AA:0.5,92s"},
+			},
+		}
+		run, err := newEquityRun(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := run.enumerate(MaxEquityBudget); err != nil {
+			t.Fatal(err)
+		}
+		exact := percent(run.equity[0], run.total)
+		run, _ = newEquityRun(opts)
+		if err := run.sample(rand.New(rand.NewSource(1)), 100000, MaxEquityBudget); err != nil {
+			t.Fatal(err)
+		}
+		sampled := percent(run.equity[0], run.total)
+		if math.Abs(exact-sampled) > 1 {
+			t.Errorf("Sampled equity is too far from the exact one, exact: %v, sampled: %v", exact, sampled)
+		}
+	})
+	t.Run("Range holdings blocked by known cards are left out", func(t *testing.T) {
+		actual, err := CalculateEquity(EquityOptions{
+			Board: []string{"2S", "7S", "QC", "9D", "3H"},
+			Players: []EquityPlayer{
+				{Id: "hero", Cards: []string{"AS", "KD"}},
+				{Id: "villain", Range: "AsAd,KhKd,QhJh"},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// only QH JH is left, which pairs the queen
+		if actual.Trials != 1 || actual.Players[1].Win != 100 {
+			t.Errorf("Blocked holdings should be left out, actual: %v", actual)
+		}
+	})
+	t.Run("Preflop ranges without known cards", func(t *testing.T) {
+		actual, err := CalculateEquity(EquityOptions{
+			Players: []EquityPlayer{
+				{Id: "hero", Range: "AA"},
+				{Id: "villain", Range: "KK"},
+			},
+			Iterations: 50000,
+			Budget:     MaxEquityBudget,
+			Seed:       "coaching",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual.Method != EquityMonteCarlo || actual.Trials != 50000 {
+			t.Errorf("Preflop ranges should be sampled, actual: %v", actual)
+		}
+		if equity := actual.Players[0].Equity; equity < 80.5 || equity > 83.5 {
+			t.Errorf("Equity of aces against kings is incorrect, actual: %v", equity)
+		}
+	})
+	t.Run("Budget stops sampling", func(t *testing.T) {
+		actual, err := CalculateEquity(EquityOptions{
+			Players: []EquityPlayer{
+				{Id: "hero", Cards: []string{"AS", "AH"}},
+				{Id: "villain", Cards: []string{"KS", "KH"}},
+			},
+			Iterations: MaxEquityIterations,
+			Budget:     time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual.Trials == 0 || actual.Trials >= MaxEquityIterations || actual.Seed == "" {
+			t.Errorf("Budget should stop sampling early, actual: %v", actual)
+		}
+	})
+	t.Run("Budget stops enumeration", func(t *testing.T) {
+		run, err := newEquityRun(EquityOptions{
+			Players: []EquityPlayer{
+				{Id: "hero", Cards: []string{"AS", "AH"}},
+				{Id: "villain", Cards: []string{"KS", "KH"}},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := run.enumerate(time.Nanosecond); err == nil || run.trials >= 2000 {
+			t.Errorf("Budget should stop enumeration early, trials: %v, error: %v", run.trials, err)
+		}
+	})
+	t.Run("Too many players", func(t *testing.T) {
+		var players []EquityPlayer
+		for i := 0; i < 24; i++ {
+			players = append(players, EquityPlayer{Id: fmt.Sprintf("player%d", i), Range: "22+,A2+,K2+,Q2+,J2+,T2+,92+,82+,72+,62+,52+,42+,32"})
+		}
+		if _, err := CalculateEquity(EquityOptions{Players: players}); err == nil {
+			t.Error("An error is expected to return for more players than a table seats")
+		}
+		if _, err := CalculateEquity(EquityOptions{Players: players[:MaxEquityPlayers], Iterations: 1000}); err != nil {
+			t.Errorf("A full table should get its equity, error: %v", err)
+		}
+	})
+	t.Run("Invalid spots", func(t *testing.T) {
+		tests := []EquityOptions{
+			{Players: []EquityPlayer{{Id: "hero", Cards: []string{"AS", "AH"}}}},
+			{Players: []EquityPlayer{{Id: "hero", Cards: []string{"AS", "AH"}}, {Id: "hero", Cards: []string{"KS", "KH"}}}},
+			{Players: []EquityPlayer{{Id: "hero", Cards: []string{"AS", "AH"}}, {Id: "villain", Cards: []string{"AS", "KH"}}}},
+			{Players: []EquityPlayer{{Id: "hero", Cards: []string{"AS"}}, {Id: "villain", Cards: []string{"KS", "KH"}}}},
+			{Players: []EquityPlayer{{Id: "hero", Cards: []string{"AS", "AH"}}, {Id: "villain"}}},
+			{Players: []EquityPlayer{{Id: "hero", Cards: []string{"AS", "AH"}}, {Id: "villain", Range: "QQ++"}}},
+			{Players: []EquityPlayer{{Id: "hero", Cards: []string{"AS", "AH"}}, {Id: "villain", Range: "AsKd"}}},
+			{Players: []EquityPlayer{{Id: "hero", Range: "AsAh"}, {Id: "villain", Range: "AsAd"}}},
+			{Board: []string{"2S", "3S", "4S", "5S", "6S", "7S"}, Players: []EquityPlayer{{Id: "hero", Cards: []string{"AS", "AH"}}, {Id: "villain", Cards: []string{"KS", "KH"}}}},
+		}
+		for _, opts := range tests {
+			if _, err := CalculateEquity(opts); err == nil {
+				t.Errorf("An error is expected to return for %v", opts)
+			}
+		}
+	})
+}
+
+func sameEquities(a, b []PlayerEquity) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
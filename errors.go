@@ -0,0 +1,81 @@
+package main
+
+import (
+	"card-game/hand"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// ErrorResponse is the body of every error response. Code is stable and meant
+// for clients to switch on, Message is for humans.
+type ErrorResponse struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// domainErrors maps the errors the API knows about to a status and code.
+var domainErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor"},
+	{ErrDeckNotFound, http.StatusNotFound, "deck_not_found"},
+	{ErrPileNotFound, http.StatusNotFound, "pile_not_found"},
+	{ErrTableNotFound, http.StatusNotFound, "table_not_found"},
+	{ErrInsufficientCards, http.StatusConflict, "insufficient_cards"},
+	{ErrDeckClosed, http.StatusConflict, "deck_closed"},
+	{ErrDeckArchived, http.StatusConflict, "deck_archived"},
+	{ErrNotRevealable, http.StatusConflict, "not_revealable"},
+	{ErrConcurrentUpdate, http.StatusConflict, "concurrent_update"},
+	{ErrIdempotencyKeyInUse, http.StatusConflict, "idempotency_key_in_use"},
+	{ErrTableAdvanced, http.StatusConflict, "table_advanced"},
+	{ErrNotEnoughPlayers, http.StatusConflict, "not_enough_players"},
+	{ErrHandInProgress, http.StatusConflict, "hand_in_progress"},
+	{ErrSeatTaken, http.StatusConflict, "seat_taken"},
+	{ErrTableDeck, http.StatusConflict, "table_deck"},
+	{ErrInvalidCount, http.StatusUnprocessableEntity, "invalid_count"},
+	{ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, "idempotency_key_reused"},
+	{hand.ErrInvalidRange, http.StatusUnprocessableEntity, "invalid_range"},
+	{ErrAPIKeyRequired, http.StatusUnauthorized, "api_key_required"},
+	{ErrPermissionDenied, http.StatusForbidden, "permission_denied"},
+	{ErrSeatTokenRequired, http.StatusUnauthorized, "seat_token_required"},
+	{ErrSeatTokenInvalid, http.StatusForbidden, "seat_token_invalid"},
+	{ErrStoreUnavailable, http.StatusServiceUnavailable, "store_unavailable"},
+}
+
+// errorStatus returns the status and code for err. Errors the API does not
+// know about get status and a code derived from it.
+func errorStatus(status int, err error) (int, string) {
+	var codeErr *CardCodeError
+	if errors.As(err, &codeErr) {
+		if len(codeErr.Invalid) > 0 || len(codeErr.Duplicates) > 0 {
+			return http.StatusUnprocessableEntity, "invalid_card_code"
+		}
+		if len(codeErr.NotInDeck) > 0 {
+			return http.StatusNotFound, "card_not_in_deck"
+		}
+		return http.StatusConflict, "cards_unavailable"
+	}
+	for _, known := range domainErrors {
+		if errors.Is(err, known.err) {
+			return known.status, known.code
+		}
+	}
+	return status, strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// respondError aborts the request with err in an ErrorResponse. status is used
+// only when err is not one of the errors the API knows about.
+func respondError(context *gin.Context, status int, err error) {
+	status, code := errorStatus(status, err)
+	response := ErrorResponse{Code: code, Message: err.Error()}
+	var codeErr *CardCodeError
+	if errors.As(err, &codeErr) {
+		response.Details = codeErr
+	}
+	context.AbortWithStatusJSON(status, response)
+}
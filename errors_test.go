@@ -0,0 +1,35 @@
+package main
+
+import (
+	"card-game/hand"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{ErrDeckNotFound, http.StatusNotFound, "deck_not_found"},
+		{ErrInsufficientCards, http.StatusConflict, "insufficient_cards"},
+		{fmt.Errorf("%w from index 3", ErrInsufficientCards), http.StatusConflict, "insufficient_cards"},
+		{fmt.Errorf("%w: must be greater than zero", ErrInvalidCount), http.StatusUnprocessableEntity, "invalid_count"},
+		{&CardCodeError{Invalid: []string{"ZZ"}}, http.StatusUnprocessableEntity, "invalid_card_code"},
+		{&CardCodeError{NotInDeck: []string{"X1"}}, http.StatusNotFound, "card_not_in_deck"},
+		{&CardCodeError{Missing: []string{"AH"}}, http.StatusConflict, "cards_unavailable"},
+		{fmt.Errorf("%w \"QQs\"", hand.ErrInvalidRange), http.StatusUnprocessableEntity, "invalid_range"},
+		{fmt.Errorf("%w: server selection timeout", ErrStoreUnavailable), http.StatusServiceUnavailable, "store_unavailable"},
+		{ErrSeatTokenInvalid, http.StatusForbidden, "seat_token_invalid"},
+		{errors.New("unknown position"), http.StatusBadRequest, "bad_request"},
+	}
+	for _, tt := range tests {
+		status, code := errorStatus(http.StatusBadRequest, tt.err)
+		if status != tt.status || code != tt.code {
+			t.Errorf("Status is incorrect for %v, expected: %v %v, actual: %v %v", tt.err, tt.status, tt.code, status, code)
+		}
+	}
+}
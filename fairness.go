@@ -0,0 +1,123 @@
+package main
+
+import (
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrNotRevealable = errors.New("deck order can only be revealed once the deck is exhausted or closed")
+
+// Reveal is everything a client needs to check a deck against its commitment.
+type Reveal struct {
+	DeckId     string   `json:"deck_id"`
+	Commitment string   `json:"commitment"`
+	Salt       string   `json:"salt"`
+	Order      []string `json:"order"`
+	// Seed is disclosed with the order so the original shuffle can be replayed,
+	// empty for decks shuffled securely.
+	Seed string `json:"seed,omitempty"`
+	// Past lists the commitments the deck had before it was reordered, oldest
+	// first, so the cards dealt under each can be checked too.
+	Past []PastCommitment `json:"past_commitments,omitempty"`
+}
+
+// PastCommitment is a commitment a reshuffle, cut or return replaced, with
+// the order it committed to and what replaced it.
+type PastCommitment struct {
+	Commitment string    `json:"commitment" bson:"commitment"`
+	Salt       string    `json:"salt" bson:"salt"`
+	Order      []string  `json:"order" bson:"order"`
+	ReplacedBy string    `json:"replaced_by" bson:"replaced_by"`
+	ReplacedAt time.Time `json:"replaced_at" bson:"replaced_at"`
+}
+
+func newSalt() string {
+	var b [32]byte
+	if _, err := cryptorand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// Commitment hashes the salt and the card codes in the order they will be
+// dealt: hex(sha256(salt + "|" + "AS,KH,...")).
+func Commitment(salt string, order []string) string {
+	sum := sha256.Sum256([]byte(salt + "|" + strings.Join(order, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyCommitment reports whether the revealed salt and order produce commitment.
+func VerifyCommitment(commitment string, salt string, order []string) bool {
+	return Commitment(salt, order) == commitment
+}
+
+// dealOrder lists the codes of cards in the order PopCards hands them out,
+// which is the reverse of how they are stored.
+func dealOrder(cards []Card) []string {
+	var order []string
+	for i := len(cards) - 1; i >= 0; i-- {
+		order = append(order, cards[i].Code)
+	}
+	return order
+}
+
+// commit records a fresh salt, the deal order and its commitment on the deck.
+func (d *Deck) commit() {
+	d.Salt = newSalt()
+	d.CommittedOrder = dealOrder(d.Cards)
+	d.Commitment = Commitment(d.Salt, d.CommittedOrder)
+}
+
+// recommit commits to the deck's new order after operation reordered it. The
+// commitment it replaces is kept for the reveal, which then still accounts for
+// every card dealt before the reorder.
+func (d *Deck) recommit(operation string) {
+	if d.Commitment != "" {
+		d.PastCommitments = append(d.PastCommitments, PastCommitment{
+			Commitment: d.Commitment,
+			Salt:       d.Salt,
+			Order:      d.CommittedOrder,
+			ReplacedBy: operation,
+			ReplacedAt: timestamp(),
+		})
+	}
+	d.commit()
+}
+
+// revealable reports whether the deck's secrets may be disclosed, which is once
+// no card is left to deal or the deck is closed.
+func (d Deck) revealable() bool {
+	return d.Closed || len(d.Cards) == 0
+}
+
+func CloseDeck(store DeckStore, deckId string) (Deck, error) {
+	return store.UpdateDeck(deckId, func(deck *Deck) error {
+		deck.Closed = true
+		return nil
+	})
+}
+
+func RevealDeck(store DeckStore, deckId string) (Reveal, error) {
+	deck, err := store.GetDeck(deckId)
+	if err != nil {
+		return Reveal{}, err
+	}
+	if deck.Commitment == "" {
+		return Reveal{}, errors.New("deck has no commitment, only shuffled decks are committed")
+	}
+	if !deck.revealable() {
+		return Reveal{}, ErrNotRevealable
+	}
+	return Reveal{
+		DeckId:     deck.DeckId,
+		Commitment: deck.Commitment,
+		Salt:       deck.Salt,
+		Order:      deck.CommittedOrder,
+		Seed:       deck.Seed,
+		Past:       deck.PastCommitments,
+	}, nil
+}
@@ -0,0 +1,97 @@
+// Package hand evaluates poker hands.
+package hand
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Value is the rank of a card, from Two up to Ace.
+type Value uint8
+
+const (
+	Two Value = iota
+	Three
+	Four
+	Five
+	Six
+	Seven
+	Eight
+	Nine
+	Ten
+	Jack
+	Queen
+	King
+	Ace
+)
+
+// Suit of a card. Suits never rank against each other.
+type Suit uint8
+
+const (
+	Spades Suit = iota
+	Hearts
+	Diamonds
+	Clubs
+)
+
+const valueCodes = "23456789TJQKA"
+const suitCodes = "SHDC"
+
+// Card packs a value and a suit into one byte.
+type Card uint8
+
+func NewCard(value Value, suit Suit) Card {
+	return Card(value)<<2 | Card(suit)
+}
+
+// ParseCard reads a canonical two character card code such as "AS" or "TH".
+func ParseCard(code string) (Card, error) {
+	if len(code) != 2 {
+		return 0, fmt.Errorf("invalid card code %q", code)
+	}
+	value := strings.IndexByte(valueCodes, code[0])
+	suit := strings.IndexByte(suitCodes, code[1])
+	if value < 0 || suit < 0 {
+		return 0, fmt.Errorf("invalid card code %q", code)
+	}
+	return NewCard(Value(value), Suit(suit)), nil
+}
+
+// ParseCards reads a list of card codes with ParseCard.
+func ParseCards(codes ...string) ([]Card, error) {
+	cards := make([]Card, len(codes))
+	for i, code := range codes {
+		card, err := ParseCard(code)
+		if err != nil {
+			return nil, err
+		}
+		cards[i] = card
+	}
+	return cards, nil
+}
+
+func (c Card) Value() Value {
+	return Value(c >> 2)
+}
+
+func (c Card) Suit() Suit {
+	return Suit(c & 3)
+}
+
+// String returns the card code, e.g. "AS".
+func (c Card) String() string {
+	return string([]byte{valueCodes[c.Value()], suitCodes[c.Suit()]})
+}
+
+var ErrHandSize = errors.New("a hand must have between 5 and 7 cards")
+
+// DuplicateCardError reports a card that appears more than once in a hand.
+type DuplicateCardError struct {
+	Card Card
+}
+
+func (e *DuplicateCardError) Error() string {
+	return fmt.Sprintf("card %s appears more than once", e.Card)
+}
@@ -0,0 +1,118 @@
+package hand
+
+import (
+	"fmt"
+	"strings"
+)
+
+var valueNames = [...]string{"two", "three", "four", "five", "six", "seven", "eight", "nine", "ten", "jack", "queen", "king", "ace"}
+
+var pluralNames = [...]string{"twos", "threes", "fours", "fives", "sixes", "sevens", "eights", "nines", "tens", "jacks", "queens", "kings", "aces"}
+
+var ordinals = [...]string{"first", "second", "third", "fourth"}
+
+func (v Value) String() string {
+	return valueNames[v]
+}
+
+// groupSizes is how many cards of Best each part of a hand of the category
+// takes, in order. Parts of size one after the first part are kickers.
+var groupSizes = [...][]int{
+	HighCard:      {1, 1, 1, 1, 1},
+	OnePair:       {2, 1, 1, 1},
+	TwoPair:       {2, 2, 1},
+	ThreeOfAKind:  {3, 1, 1},
+	Straight:      {5},
+	Flush:         {1, 1, 1, 1, 1},
+	FullHouse:     {3, 2},
+	FourOfAKind:   {4, 1},
+	StraightFlush: {5},
+	RoyalFlush:    {5},
+}
+
+// kickers lists the values of the kickers of the hand.
+func (r Result) kickers() []string {
+	var kickers []string
+	i := 0
+	for part, size := range groupSizes[r.Category] {
+		if part > 0 && size == 1 {
+			kickers = append(kickers, r.Best[i].Value().String())
+		}
+		i += size
+	}
+	return kickers
+}
+
+// String describes the hand, e.g. "pair of aces, kickers king, nine, four".
+func (r Result) String() string {
+	if len(r.Best) != 5 {
+		return r.Category.String()
+	}
+	top := r.Best[0].Value()
+	var description string
+	switch r.Category {
+	case HighCard:
+		description = fmt.Sprintf("%s high", top)
+	case OnePair:
+		description = fmt.Sprintf("pair of %s", pluralNames[top])
+	case TwoPair:
+		description = fmt.Sprintf("two pair, %s and %s", pluralNames[top], pluralNames[r.Best[2].Value()])
+	case ThreeOfAKind:
+		description = fmt.Sprintf("three of a kind, %s", pluralNames[top])
+	case Straight:
+		description = fmt.Sprintf("straight, %s high", top)
+	case Flush:
+		description = fmt.Sprintf("flush, %s high", top)
+	case FullHouse:
+		description = fmt.Sprintf("full house, %s full of %s", pluralNames[top], pluralNames[r.Best[3].Value()])
+	case FourOfAKind:
+		description = fmt.Sprintf("four of a kind, %s", pluralNames[top])
+	case StraightFlush:
+		description = fmt.Sprintf("straight flush, %s high", top)
+	case RoyalFlush:
+		return "royal flush"
+	}
+	switch kickers := r.kickers(); len(kickers) {
+	case 0:
+	case 1:
+		description += ", kicker " + kickers[0]
+	default:
+		description += ", kickers " + strings.Join(kickers, ", ")
+	}
+	return description
+}
+
+// Explain says why hand a beats hand b, naming the kicker when it is the
+// kicker that decides. It returns "" when neither hand beats the other.
+func Explain(a, b Result) string {
+	if a.Rank == b.Rank {
+		return ""
+	}
+	if a.Rank < b.Rank {
+		a, b = b, a
+	}
+	if a.Category != b.Category {
+		return fmt.Sprintf("%s beats %s", a, b)
+	}
+	i := 0
+	kicker := 0
+	for part, size := range groupSizes[a.Category] {
+		isKicker := part > 0 && size == 1
+		high, low := a.Best[i].Value(), b.Best[i].Value()
+		if high != low {
+			if isKicker {
+				return fmt.Sprintf("%s beats %s on the %s kicker, %s over %s",
+					a, b, ordinals[kicker], high, low)
+			}
+			if size == 1 || size == 5 {
+				return fmt.Sprintf("%s beats %s, %s over %s", a, b, high, low)
+			}
+			return fmt.Sprintf("%s beats %s, %s over %s", a, b, pluralNames[high], pluralNames[low])
+		}
+		if isKicker {
+			kicker++
+		}
+		i += size
+	}
+	return fmt.Sprintf("%s beats %s", a, b)
+}
@@ -0,0 +1,80 @@
+package hand
+
+import "testing"
+
+func evaluate(t *testing.T, codes ...string) Result {
+	cards, err := ParseCards(codes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Evaluate(cards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestResultString(t *testing.T) {
+	tests := []struct {
+		codes    []string
+		expected string
+	}{
+		{[]string{"AS", "KS", "QS", "JS", "TS"}, "royal flush"},
+		{[]string{"5H", "3H", "AH", "2H", "4H"}, "straight flush, five high"},
+		{[]string{"9C", "9D", "AS", "9S", "9H"}, "four of a kind, nines, kicker ace"},
+		{[]string{"KD", "KS", "7H", "7C", "KC"}, "full house, kings full of sevens"},
+		{[]string{"2D", "9D", "JD", "4D", "KD"}, "flush, king high, kickers jack, nine, four, two"},
+		{[]string{"AS", "2D", "3C", "4H", "5S"}, "straight, five high"},
+		{[]string{"QH", "QC", "QD", "2S", "7C"}, "three of a kind, queens, kickers seven, two"},
+		{[]string{"8S", "8C", "4D", "4H", "AC"}, "two pair, eights and fours, kicker ace"},
+		{[]string{"JS", "JH", "9C", "6D", "2S"}, "pair of jacks, kickers nine, six, two"},
+		{[]string{"KS", "TD", "8C", "6H", "4S"}, "king high, kickers ten, eight, six, four"},
+	}
+	for _, tt := range tests {
+		if actual := evaluate(t, tt.codes...).String(); actual != tt.expected {
+			t.Errorf("Description of %v is incorrect, expected: %q, actual: %q", tt.codes, tt.expected, actual)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	tests := []struct {
+		a, b     []string
+		expected string
+	}{
+		{
+			[]string{"KD", "KS", "7H", "7C", "KC"}, []string{"2D", "9D", "JD", "4D", "KD"},
+			"full house, kings full of sevens beats flush, king high, kickers jack, nine, four, two",
+		},
+		{
+			[]string{"AS", "AD", "KC", "9H", "4S"}, []string{"AH", "AC", "QC", "9D", "4D"},
+			"pair of aces, kickers king, nine, four beats pair of aces, kickers queen, nine, four on the first kicker, king over queen",
+		},
+		{
+			[]string{"8S", "8C", "4D", "4H", "AC"}, []string{"8H", "8D", "4S", "4C", "KC"},
+			"two pair, eights and fours, kicker ace beats two pair, eights and fours, kicker king on the first kicker, ace over king",
+		},
+		{
+			[]string{"6S", "2D", "3C", "4H", "5S"}, []string{"AS", "2C", "3D", "4D", "5D"},
+			"straight, six high beats straight, five high, six over five",
+		},
+		{
+			[]string{"9S", "9C", "4D", "4H", "AC"}, []string{"8H", "8D", "7S", "7C", "KC"},
+			"two pair, nines and fours, kicker ace beats two pair, eights and sevens, kicker king, nines over eights",
+		},
+	}
+	for _, tt := range tests {
+		a, b := evaluate(t, tt.a...), evaluate(t, tt.b...)
+		if actual := Explain(a, b); actual != tt.expected {
+			t.Errorf("Explanation is incorrect, expected: %q, actual: %q", tt.expected, actual)
+		}
+		if actual := Explain(b, a); actual != tt.expected {
+			t.Errorf("Explanation should not depend on the order, expected: %q, actual: %q", tt.expected, actual)
+		}
+	}
+	a := evaluate(t, "AS", "KS", "QD", "JC", "9H")
+	b := evaluate(t, "AD", "KD", "QS", "JH", "9C")
+	if actual := Explain(a, b); actual != "" {
+		t.Errorf("Equal hands should have no explanation, actual: %q", actual)
+	}
+}
@@ -0,0 +1,151 @@
+package hand
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// Category is the kind of a poker hand, from HighCard up to RoyalFlush.
+type Category uint8
+
+const (
+	HighCard Category = iota
+	OnePair
+	TwoPair
+	ThreeOfAKind
+	Straight
+	Flush
+	FullHouse
+	FourOfAKind
+	StraightFlush
+	RoyalFlush
+)
+
+var categoryNames = [...]string{
+	"high_card", "one_pair", "two_pair", "three_of_a_kind", "straight",
+	"flush", "full_house", "four_of_a_kind", "straight_flush", "royal_flush",
+}
+
+func (c Category) String() string {
+	return categoryNames[c]
+}
+
+func (c Category) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Category) UnmarshalText(text []byte) error {
+	for i, name := range categoryNames {
+		if name == string(text) {
+			*c = Category(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown hand category %q", text)
+}
+
+// Rank orders hands: a hand with a higher rank beats one with a lower rank and
+// hands of equal rank split the pot. It runs from 1 for 7-5-4-3-2 offsuit up
+// to MaxRank for a royal flush.
+type Rank uint16
+
+// MaxRank is the rank of a royal flush. There are this many distinct five card
+// hands once suits are ignored.
+const MaxRank Rank = 7462
+
+// Result is the best five card hand found among the cards evaluated.
+type Result struct {
+	Category Category
+	Rank     Rank
+	// Best holds the five cards of the hand in the order it reads: the cards
+	// of the largest group first, higher values first within a group, and the
+	// ace last in a five-high straight.
+	Best []Card
+}
+
+// Evaluate finds the best five card hand among 5 to 7 cards.
+func Evaluate(cards []Card) (Result, error) {
+	if len(cards) < 5 || len(cards) > 7 {
+		return Result{}, ErrHandSize
+	}
+	var seen uint64
+	for _, card := range cards {
+		if seen&(1<<card) != 0 {
+			return Result{}, &DuplicateCardError{Card: card}
+		}
+		seen |= 1 << card
+	}
+
+	best, bestRank := best5(cards)
+	return Result{
+		Category: categories[bestRank],
+		Rank:     bestRank,
+		Best:     order(best, categories[bestRank]),
+	}, nil
+}
+
+// RankOf returns the rank of the best five card hand among 5 to 7 cards. It
+// does not check the cards, which must be distinct, and is meant for loops
+// that evaluate many hands.
+func RankOf(cards []Card) Rank {
+	_, rank := best5(cards)
+	return rank
+}
+
+// best5 tries every five card subset, at most 21 of them for seven cards.
+func best5(cards []Card) (best [5]Card, bestRank Rank) {
+	var hand [5]Card
+	n := len(cards)
+	for a := 0; a < n; a++ {
+		for b := a + 1; b < n; b++ {
+			for c := b + 1; c < n; c++ {
+				for d := c + 1; d < n; d++ {
+					for e := d + 1; e < n; e++ {
+						hand = [5]Card{cards[a], cards[b], cards[c], cards[d], cards[e]}
+						if rank := rank5(hand); rank > bestRank {
+							bestRank, best = rank, hand
+						}
+					}
+				}
+			}
+		}
+	}
+	return best, bestRank
+}
+
+// rank5 looks up the rank of exactly five distinct cards.
+func rank5(h [5]Card) Rank {
+	mask := 1<<h[0].Value() | 1<<h[1].Value() | 1<<h[2].Value() | 1<<h[3].Value() | 1<<h[4].Value()
+	suit := h[0].Suit()
+	if h[1].Suit() == suit && h[2].Suit() == suit && h[3].Suit() == suit && h[4].Suit() == suit {
+		return flushRanks[mask]
+	}
+	if bits.OnesCount16(uint16(mask)) == 5 {
+		return uniqueRanks[mask]
+	}
+	return pairedRanks[primes[h[0].Value()]*primes[h[1].Value()]*primes[h[2].Value()]*primes[h[3].Value()]*primes[h[4].Value()]]
+}
+
+// order sorts the cards of a five card hand the way the hand reads.
+func order(hand [5]Card, category Category) []Card {
+	var counts [13]int
+	for _, card := range hand {
+		counts[card.Value()]++
+	}
+	cards := hand[:]
+	sort.Slice(cards, func(i, j int) bool {
+		ci, cj := counts[cards[i].Value()], counts[cards[j].Value()]
+		if ci != cj {
+			return ci > cj
+		}
+		if cards[i].Value() != cards[j].Value() {
+			return cards[i].Value() > cards[j].Value()
+		}
+		return cards[i].Suit() < cards[j].Suit()
+	})
+	if (category == Straight || category == StraightFlush) && cards[0].Value() == Ace && cards[1].Value() == Five {
+		cards = append(cards[1:], cards[0])
+	}
+	return cards
+}
@@ -0,0 +1,236 @@
+package hand
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// reference scores five cards the slow, obvious way: the category followed by
+// the values that break ties, packed so that bigger scores are better hands.
+func reference(h [5]Card) (Category, int) {
+	var counts [13]int
+	flush, mask := true, 0
+	for _, card := range h {
+		counts[card.Value()]++
+		flush = flush && card.Suit() == h[0].Suit()
+		mask |= 1 << card.Value()
+	}
+	values := make([]int, 0, 5)
+	for _, card := range h {
+		values = append(values, int(card.Value()))
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] > values[j]
+	})
+	straight := false
+	for top := 4; top <= 12; top++ {
+		straight = straight || mask == 0x1f<<(top-4)
+	}
+	if mask == 1<<Ace|0x0f {
+		straight, values = true, []int{3, 2, 1, 0, -1}
+	}
+	var category Category
+	switch {
+	case straight && flush && values[0] == int(Ace):
+		category = RoyalFlush
+	case straight && flush:
+		category = StraightFlush
+	case counts[values[0]] == 4:
+		category = FourOfAKind
+	case counts[values[0]] == 3 && counts[values[3]] == 2:
+		category = FullHouse
+	case flush:
+		category = Flush
+	case straight:
+		category = Straight
+	case counts[values[0]] == 3:
+		category = ThreeOfAKind
+	case counts[values[0]] == 2 && counts[values[2]] == 2:
+		category = TwoPair
+	case counts[values[0]] == 2:
+		category = OnePair
+	}
+	score := int(category)
+	if category == RoyalFlush {
+		score = int(StraightFlush)
+	}
+	for _, value := range values {
+		score = score*16 + value + 1
+	}
+	return category, score
+}
+
+func TestEvaluateEveryFiveCardHand(t *testing.T) {
+	expected := map[Category]int{
+		RoyalFlush:    4,
+		StraightFlush: 36,
+		FourOfAKind:   624,
+		FullHouse:     3744,
+		Flush:         5108,
+		Straight:      10200,
+		ThreeOfAKind:  54912,
+		TwoPair:       123552,
+		OnePair:       1098240,
+		HighCard:      1302540,
+	}
+	actual := make(map[Category]int)
+	// scores[rank] is the reference score every hand of that rank must share
+	var scores [MaxRank + 1]int
+	for a := Card(0); a < 52; a++ {
+		for b := a + 1; b < 52; b++ {
+			for c := b + 1; c < 52; c++ {
+				for d := c + 1; d < 52; d++ {
+					for e := d + 1; e < 52; e++ {
+						h := [5]Card{a, b, c, d, e}
+						rank := rank5(h)
+						category, score := reference(h)
+						if rank < 1 || rank > MaxRank {
+							t.Fatalf("Rank of %v is out of range: %d", h, rank)
+						}
+						if categories[rank] != category {
+							t.Fatalf("Category of %v is incorrect, expected: %v, actual: %v", h, category, categories[rank])
+						}
+						if scores[rank] != 0 && scores[rank] != score {
+							t.Fatalf("Hands of rank %d are not equal, %v differs from the others", rank, h)
+						}
+						scores[rank] = score
+						actual[category]++
+					}
+				}
+			}
+		}
+	}
+	for category, count := range expected {
+		if actual[category] != count {
+			t.Errorf("Number of %v hands is incorrect, expected: %d, actual: %d", category, count, actual[category])
+		}
+	}
+	for rank := Rank(1); rank <= MaxRank; rank++ {
+		if scores[rank] == 0 {
+			t.Errorf("No hand has rank %d", rank)
+		} else if rank > 1 && scores[rank] <= scores[rank-1] {
+			t.Errorf("Rank %d does not beat rank %d", rank, rank-1)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		codes    []string
+		category Category
+		best     string
+	}{
+		{[]string{"AS", "KS", "QS", "JS", "TS", "2D", "3C"}, RoyalFlush, "[AS KS QS JS TS]"},
+		{[]string{"5H", "3H", "AH", "2H", "4H", "6C", "KH"}, StraightFlush, "[5H 4H 3H 2H AH]"},
+		{[]string{"9C", "9D", "AS", "9S", "9H", "AD", "AC"}, FourOfAKind, "[9S 9H 9D 9C AS]"},
+		{[]string{"KD", "KS", "7H", "7C", "KC", "7D"}, FullHouse, "[KS KD KC 7H 7C]"},
+		{[]string{"2D", "9D", "JD", "4D", "KD", "KC", "KH"}, Flush, "[KD JD 9D 4D 2D]"},
+		{[]string{"AS", "2D", "3C", "4H", "5S", "KD", "QC"}, Straight, "[5S 4H 3C 2D AS]"},
+		{[]string{"QH", "QC", "QD", "2S", "7C"}, ThreeOfAKind, "[QH QD QC 7C 2S]"},
+		{[]string{"8S", "8C", "4D", "4H", "AC", "AD", "3S"}, TwoPair, "[AD AC 8S 8C 4D]"},
+		{[]string{"JS", "JH", "9C", "6D", "2S", "3H", "4C"}, OnePair, "[JS JH 9C 6D 4C]"},
+		{[]string{"KS", "TD", "8C", "6H", "4S", "3D", "2C"}, HighCard, "[KS TD 8C 6H 4S]"},
+	}
+	for _, tt := range tests {
+		cards, err := ParseCards(tt.codes...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := Evaluate(cards)
+		if err != nil {
+			t.Fatalf("Failed to evaluate %v: %v", tt.codes, err)
+		}
+		if actual.Category != tt.category {
+			t.Errorf("Category of %v is incorrect, expected: %v, actual: %v", tt.codes, tt.category, actual.Category)
+		}
+		if best := fmtCards(actual.Best); best != tt.best {
+			t.Errorf("Best cards of %v are incorrect, expected: %s, actual: %s", tt.codes, tt.best, best)
+		}
+	}
+}
+
+func TestEvaluatePicksTheBestFive(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	deck := make([]Card, 52)
+	for i := range deck {
+		deck[i] = Card(i)
+	}
+	for i := 0; i < 2000; i++ {
+		random.Shuffle(len(deck), func(i, j int) { deck[i], deck[j] = deck[j], deck[i] })
+		actual, err := Evaluate(deck[:7])
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, best := reference(fromSlice(actual.Best))
+		for skip1 := 0; skip1 < 7; skip1++ {
+			for skip2 := skip1 + 1; skip2 < 7; skip2++ {
+				var h [5]Card
+				n := 0
+				for k, card := range deck[:7] {
+					if k != skip1 && k != skip2 {
+						h[n] = card
+						n++
+					}
+				}
+				if _, score := reference(h); score > best {
+					t.Fatalf("Evaluate of %v chose %v over %v", deck[:7], actual.Best, h)
+				}
+			}
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	cards, _ := ParseCards("AS", "KS", "QS", "JS", "TS", "9S", "8S", "7S")
+	for _, n := range []int{0, 4, 8} {
+		if _, err := Evaluate(cards[:n]); !errors.Is(err, ErrHandSize) {
+			t.Errorf("Evaluating %d cards should fail with ErrHandSize, actual: %v", n, err)
+		}
+	}
+	cards[4] = cards[0]
+	var duplicate *DuplicateCardError
+	if _, err := Evaluate(cards[:5]); !errors.As(err, &duplicate) || duplicate.Card != cards[0] {
+		t.Errorf("Evaluating a repeated card should fail with DuplicateCardError, actual: %v", err)
+	}
+}
+
+func TestParseCard(t *testing.T) {
+	for _, code := range []string{"", "A", "1S", "ZZ", "AX", "10S", "as", "X1"} {
+		if _, err := ParseCard(code); err == nil {
+			t.Errorf("An error is expected to return for %q", code)
+		}
+	}
+	for i := Card(0); i < 52; i++ {
+		if actual, err := ParseCard(i.String()); err != nil || actual != i {
+			t.Errorf("Card %v does not round trip, actual: %v, error: %v", i, actual, err)
+		}
+	}
+}
+
+func BenchmarkEvaluateSevenCards(b *testing.B) {
+	cards, _ := ParseCards("AS", "KD", "9C", "9H", "4S", "3D", "2C")
+	for i := 0; i < b.N; i++ {
+		Evaluate(cards)
+	}
+}
+
+func fmtCards(cards []Card) string {
+	s := "["
+	for i, card := range cards {
+		if i > 0 {
+			s += " "
+		}
+		s += card.String()
+	}
+	return s + "]"
+}
+
+func fromSlice(cards []Card) (h [5]Card) {
+	copy(h[:], cards)
+	return h
+}
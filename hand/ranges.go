@@ -0,0 +1,241 @@
+package hand
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidRange = errors.New("invalid range")
+
+// StartingHands is how many two card combinations a deck deals.
+const StartingHands = 1326
+
+// Combo is one pair of hole cards and how often a range holds it, from just
+// above 0 up to 1 for always.
+type Combo struct {
+	Cards  [2]Card
+	Weight float64
+}
+
+// Range is a set of combos, each listed once.
+type Range []Combo
+
+// ParseRange expands comma separated range notation into combos:
+//
+//	QQ        every pair of queens
+//	AKs, AKo  ace king suited or offsuit, AK for both
+//	QQ+, ATs+ the pair or kicker and every better one below the top card
+//	22-55     pairs from twos to fives
+//	A2s-A5s   the top card with every kicker from two to five
+//	AsKs      exactly the ace and king of spades
+//
+// Any part may end in a weight, e.g. "AKs:0.5", and a combo listed twice
+// takes the weight given last.
+func ParseRange(notation string) (Range, error) {
+	var r Range
+	index := make(map[[2]Card]int)
+	for _, part := range strings.Split(notation, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		combos, err := parseRangePart(part)
+		if err != nil {
+			return nil, err
+		}
+		for _, combo := range combos {
+			if i, ok := index[combo.Cards]; ok {
+				r[i].Weight = combo.Weight
+				continue
+			}
+			index[combo.Cards] = len(r)
+			r = append(r, combo)
+		}
+	}
+	if len(r) == 0 {
+		return nil, fmt.Errorf("%w: no hands listed", ErrInvalidRange)
+	}
+	return r, nil
+}
+
+func parseRangePart(part string) ([]Combo, error) {
+	hands, weight := part, 1.0
+	if i := strings.IndexByte(part, ':'); i >= 0 {
+		hands = part[:i]
+		w, err := strconv.ParseFloat(part[i+1:], 64)
+		if err != nil || w <= 0 || w > 1 {
+			return nil, fmt.Errorf("%w %q: weight must be above 0 and at most 1", ErrInvalidRange, part)
+		}
+		weight = w
+	}
+	invalid := fmt.Errorf("%w %q", ErrInvalidRange, part)
+
+	var classes []handClass
+	switch {
+	case len(hands) == 4 && isSuit(hands[1]) && isSuit(hands[3]):
+		first, err1 := ParseCard(strings.ToUpper(hands[:2]))
+		second, err2 := ParseCard(strings.ToUpper(hands[2:]))
+		if err1 != nil || err2 != nil || first == second {
+			return nil, invalid
+		}
+		return []Combo{{Cards: orderCombo(first, second), Weight: weight}}, nil
+	case strings.Contains(hands, "-"):
+		from, to, _ := strings.Cut(hands, "-")
+		low, ok1 := parseHandClass(from)
+		high, ok2 := parseHandClass(to)
+		if !ok1 || !ok2 || low.pair() != high.pair() || low.suited != high.suited || low.offsuit != high.offsuit {
+			return nil, invalid
+		}
+		if !low.pair() && low.high != high.high {
+			return nil, invalid
+		}
+		if low.low > high.low {
+			low, high = high, low
+		}
+		for class := low; class.low <= high.low; class = class.next() {
+			classes = append(classes, class)
+		}
+	case strings.HasSuffix(hands, "+"):
+		class, ok := parseHandClass(strings.TrimSuffix(hands, "+"))
+		if !ok {
+			return nil, invalid
+		}
+		for top := class.top(); class.low <= top; class = class.next() {
+			classes = append(classes, class)
+		}
+	default:
+		class, ok := parseHandClass(hands)
+		if !ok {
+			return nil, invalid
+		}
+		classes = append(classes, class)
+	}
+
+	var combos []Combo
+	for _, class := range classes {
+		for _, cards := range class.combos() {
+			combos = append(combos, Combo{Cards: cards, Weight: weight})
+		}
+	}
+	return combos, nil
+}
+
+// handClass is a starting hand without suits, such as QQ, AKs or T9o. A class
+// that is neither suited nor offsuit holds both.
+type handClass struct {
+	high, low       Value
+	suited, offsuit bool
+}
+
+func parseHandClass(s string) (handClass, bool) {
+	if len(s) < 2 || len(s) > 3 {
+		return handClass{}, false
+	}
+	high := strings.IndexByte(valueCodes, upper(s[0]))
+	low := strings.IndexByte(valueCodes, upper(s[1]))
+	if high < 0 || low < 0 {
+		return handClass{}, false
+	}
+	if high < low {
+		high, low = low, high
+	}
+	class := handClass{high: Value(high), low: Value(low)}
+	if len(s) == 3 {
+		switch s[2] {
+		case 's', 'S':
+			class.suited = true
+		case 'o', 'O':
+			class.offsuit = true
+		default:
+			return handClass{}, false
+		}
+		if class.pair() {
+			return handClass{}, false
+		}
+	}
+	return class, true
+}
+
+func (c handClass) pair() bool {
+	return c.high == c.low
+}
+
+// next is the class one step better: the next pair up, or the next kicker.
+func (c handClass) next() handClass {
+	if c.pair() {
+		c.high++
+	}
+	c.low++
+	return c
+}
+
+// top is the best low card "+" climbs to: aces for pairs and one below the
+// top card otherwise.
+func (c handClass) top() Value {
+	if c.pair() {
+		return Ace
+	}
+	return c.high - 1
+}
+
+func (c handClass) combos() [][2]Card {
+	var combos [][2]Card
+	for s1 := Spades; s1 <= Clubs; s1++ {
+		for s2 := Spades; s2 <= Clubs; s2++ {
+			switch {
+			case c.pair() && s2 <= s1:
+			case !c.pair() && c.suited && s1 != s2:
+			case !c.pair() && c.offsuit && s1 == s2:
+			default:
+				combos = append(combos, [2]Card{NewCard(c.high, s1), NewCard(c.low, s2)})
+			}
+		}
+	}
+	return combos
+}
+
+// orderCombo puts the higher card first, so every combo has one spelling.
+func orderCombo(a, b Card) [2]Card {
+	if a.Value() < b.Value() || (a.Value() == b.Value() && a.Suit() > b.Suit()) {
+		a, b = b, a
+	}
+	return [2]Card{a, b}
+}
+
+func isSuit(b byte) bool {
+	return strings.IndexByte(suitCodes, upper(b)) >= 0
+}
+
+func upper(b byte) byte {
+	if 'a' <= b && b <= 'z' {
+		return b - 'a' + 'A'
+	}
+	return b
+}
+
+// Without returns the combos that share no card with dead, the cards known to
+// be elsewhere such as the board or another player's hand.
+func (r Range) Without(dead ...Card) Range {
+	var blocked uint64
+	for _, card := range dead {
+		blocked |= 1 << card
+	}
+	var live Range
+	for _, combo := range r {
+		if blocked&(1<<combo.Cards[0]|1<<combo.Cards[1]) == 0 {
+			live = append(live, combo)
+		}
+	}
+	return live
+}
+
+// Weighted is the number of combos counting each by its weight.
+func (r Range) Weighted() float64 {
+	total := 0.0
+	for _, combo := range r {
+		total += combo.Weight
+	}
+	return total
+}
@@ -0,0 +1,93 @@
+package hand
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		notation string
+		combos   int
+	}{
+		{"QQ", 6},
+		{"AKs", 4},
+		{"AKo", 12},
+		{"AK", 16},
+		{"T9o", 12},
+		{"QQ+", 18},
+		{"22+", 78},
+		{"22-55", 24},
+		{"55-22", 24},
+		{"A2s-A5s", 16},
+		{"ATs+", 16},
+		{"KQo+", 12},
+		{"AsKs", 1},
+		{"AsKs, AKs", 4},
+		{"QQ+,AK", 34},
+		{"kqS, 9t", 20},
+	}
+	for _, tt := range tests {
+		r, err := ParseRange(tt.notation)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", tt.notation, err)
+			continue
+		}
+		if len(r) != tt.combos {
+			t.Errorf("Number of combos in %q is incorrect, expected: %d, actual: %d", tt.notation, tt.combos, len(r))
+		}
+	}
+}
+
+func TestParseRangeEveryStartingHand(t *testing.T) {
+	r, err := ParseRange("22+,A2+,K2+,Q2+,J2+,T2+,92+,82+,72+,62+,52+,42+,32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[[2]Card]bool)
+	for _, combo := range r {
+		if combo.Cards[0] == combo.Cards[1] || seen[combo.Cards] {
+			t.Fatalf("Combo %v is invalid or listed twice", combo.Cards)
+		}
+		seen[combo.Cards] = true
+	}
+	if len(r) != StartingHands {
+		t.Errorf("Number of starting hands is incorrect, expected: %d, actual: %d", StartingHands, len(r))
+	}
+}
+
+func TestParseRangeWeights(t *testing.T) {
+	r, err := ParseRange("QQ+:0.5, AA, AKs:0.25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// aces are listed again without a weight, so they count in full
+	if expected := 12*0.5 + 6 + 4*0.25; r.Weighted() != expected {
+		t.Errorf("Weighted combos are incorrect, expected: %v, actual: %v", expected, r.Weighted())
+	}
+}
+
+func TestRangeWithout(t *testing.T) {
+	r, _ := ParseRange("AA,AKs,KK")
+	dead, _ := ParseCards("AS", "KD")
+	live := r.Without(dead...)
+	// AA and KK keep 3 combos each, AKs loses spades and diamonds
+	if len(live) != 8 {
+		t.Errorf("Number of live combos is incorrect, expected: %d, actual: %d", 8, len(live))
+	}
+	for _, combo := range live {
+		for _, card := range combo.Cards {
+			if card == dead[0] || card == dead[1] {
+				t.Errorf("Combo %v holds a dead card", combo.Cards)
+			}
+		}
+	}
+}
+
+func TestParseRangeErrors(t *testing.T) {
+	for _, notation := range []string{"", ",", "Q", "QQs", "AKx", "AKQ", "1A", "22-A5s", "A2s-K5s", "A2s-A5o", "AsAs", "AsXs", "AK:0", "AK:1.5", "AK:x", "QQ++"} {
+		if _, err := ParseRange(notation); !errors.Is(err, ErrInvalidRange) {
+			t.Errorf("ErrInvalidRange is expected to return for %q, actual: %v", notation, err)
+		}
+	}
+}
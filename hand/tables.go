@@ -0,0 +1,139 @@
+package hand
+
+// The lookup tables follow Cactus Kev's evaluator. Five distinct values are
+// looked up by the bit mask of the values, in flushRanks when the cards share
+// a suit and in uniqueRanks otherwise. Hands with a repeated value are looked
+// up by the product of one prime per value, which is the same for every order
+// of the cards and different for every multiset of values.
+
+var primes = [13]uint32{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41}
+
+var (
+	flushRanks  [1 << 13]Rank
+	uniqueRanks [1 << 13]Rank
+	pairedRanks = make(map[uint32]Rank, 4888)
+	// categories holds the category of every rank
+	categories [MaxRank + 1]Category
+)
+
+// straightMasks lists the value masks of the ten straights, five-high first.
+var straightMasks = func() []int {
+	masks := []int{1<<Ace | 0x0f}
+	for low := Two; low <= Ten; low++ {
+		masks = append(masks, 0x1f<<low)
+	}
+	return masks
+}()
+
+func init() {
+	// ranks are handed out from the weakest hand up
+	var next Rank
+	assign := func(category Category) Rank {
+		next++
+		categories[next] = category
+		return next
+	}
+	isStraight := make(map[int]bool)
+	for _, mask := range straightMasks {
+		isStraight[mask] = true
+	}
+	// comparing two sets of five distinct values card by card from the top is
+	// the same as comparing their masks, so ascending masks are ascending hands
+	var highCards []int
+	for mask := 0; mask < 1<<13; mask++ {
+		if popcount(mask) == 5 && !isStraight[mask] {
+			highCards = append(highCards, mask)
+		}
+	}
+	product := func(counts map[Value]int) uint32 {
+		p := uint32(1)
+		for value, count := range counts {
+			for i := 0; i < count; i++ {
+				p *= primes[value]
+			}
+		}
+		return p
+	}
+	kickers := func(count int, exclude ...Value) []map[Value]int {
+		var masks []int
+		for mask := 0; mask < 1<<13; mask++ {
+			if popcount(mask) != count {
+				continue
+			}
+			excluded := false
+			for _, value := range exclude {
+				excluded = excluded || mask&(1<<value) != 0
+			}
+			if !excluded {
+				masks = append(masks, mask)
+			}
+		}
+		var sets []map[Value]int
+		for _, mask := range masks {
+			set := make(map[Value]int)
+			for value := Two; value <= Ace; value++ {
+				if mask&(1<<value) != 0 {
+					set[value] = 1
+				}
+			}
+			sets = append(sets, set)
+		}
+		return sets
+	}
+
+	for _, mask := range highCards {
+		uniqueRanks[mask] = assign(HighCard)
+	}
+	for pair := Two; pair <= Ace; pair++ {
+		for _, set := range kickers(3, pair) {
+			set[pair] = 2
+			pairedRanks[product(set)] = assign(OnePair)
+		}
+	}
+	for high := Three; high <= Ace; high++ {
+		for low := Two; low < high; low++ {
+			for _, set := range kickers(1, high, low) {
+				set[high], set[low] = 2, 2
+				pairedRanks[product(set)] = assign(TwoPair)
+			}
+		}
+	}
+	for trips := Two; trips <= Ace; trips++ {
+		for _, set := range kickers(2, trips) {
+			set[trips] = 3
+			pairedRanks[product(set)] = assign(ThreeOfAKind)
+		}
+	}
+	for _, mask := range straightMasks {
+		uniqueRanks[mask] = assign(Straight)
+	}
+	for _, mask := range highCards {
+		flushRanks[mask] = assign(Flush)
+	}
+	for trips := Two; trips <= Ace; trips++ {
+		for pair := Two; pair <= Ace; pair++ {
+			if pair != trips {
+				pairedRanks[product(map[Value]int{trips: 3, pair: 2})] = assign(FullHouse)
+			}
+		}
+	}
+	for quads := Two; quads <= Ace; quads++ {
+		for kicker := Two; kicker <= Ace; kicker++ {
+			if kicker != quads {
+				pairedRanks[product(map[Value]int{quads: 4, kicker: 1})] = assign(FourOfAKind)
+			}
+		}
+	}
+	for _, mask := range straightMasks {
+		flushRanks[mask] = assign(StraightFlush)
+	}
+	categories[next] = RoyalFlush
+}
+
+func popcount(mask int) int {
+	count := 0
+	for ; mask != 0; mask &= mask - 1 {
+		count++
+	}
+	return count
+}
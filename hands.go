@@ -0,0 +1,158 @@
+package main
+
+import (
+	"card-game/hand"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HandResult is the best five card poker hand among the cards evaluated.
+type HandResult struct {
+	Category hand.Category `json:"category"`
+	// Rank orders hands, a higher rank beats a lower one and equal ranks tie.
+	Rank        hand.Rank `json:"rank"`
+	Best        []Card    `json:"best"`
+	Description string    `json:"description"`
+	result      hand.Result
+}
+
+// EvaluateHand finds the best poker hand among 5 to 7 card codes. Every code
+// must be a distinct card of a standard deck, jokers are not played.
+func EvaluateHand(codes []string) (HandResult, error) {
+	if err := validateHandSize(len(codes)); err != nil {
+		return HandResult{}, err
+	}
+	canonical, err := ParseCardCodes(strings.Join(codes, ","), DeckOptions{}.CardCodes())
+	if err != nil {
+		return HandResult{}, err
+	}
+	return evaluateCodes(canonical)
+}
+
+func validateHandSize(n int) error {
+	if n < 5 || n > 7 {
+		return fmt.Errorf("%w: a hand needs 5 to 7 cards", ErrInvalidCount)
+	}
+	return nil
+}
+
+// evaluateCodes evaluates canonical codes already checked to be distinct.
+func evaluateCodes(codes []string) (HandResult, error) {
+	cards, err := hand.ParseCards(codes...)
+	if err != nil {
+		return HandResult{}, err
+	}
+	result, err := hand.Evaluate(cards)
+	if err != nil {
+		return HandResult{}, err
+	}
+	best := make([]Card, len(result.Best))
+	for i, card := range result.Best {
+		best[i], _ = ParseCard(card.String())
+	}
+	return HandResult{
+		Category:    result.Category,
+		Rank:        result.Rank,
+		Best:        best,
+		Description: result.String(),
+		result:      result,
+	}, nil
+}
+
+// ShowdownPlayer is a player's hole cards at a showdown.
+type ShowdownPlayer struct {
+	Id    string   `json:"id"`
+	Cards []string `json:"cards"`
+}
+
+// PlayerHand is the hand a player shows down.
+type PlayerHand struct {
+	Player string `json:"player"`
+	HandResult
+}
+
+// Showdown is the outcome of comparing every player's hand.
+type Showdown struct {
+	// Winners lists every player holding the best hand, more than one when
+	// they split the pot.
+	Winners []string `json:"winners"`
+	Split   bool     `json:"split"`
+	// Hands lists every player's hand, best first.
+	Hands []PlayerHand `json:"hands"`
+	// Reasons explains the result, one sentence per player who did not win.
+	Reasons []string `json:"reasons"`
+}
+
+// CompareHands decides who wins a Hold'em showdown between at least two
+// players, each playing their 2 hole cards with a shared board of 3 to 5
+// cards. Every card must be a distinct card of a standard deck.
+func CompareHands(board []string, players []ShowdownPlayer) (Showdown, error) {
+	if len(players) < 2 {
+		return Showdown{}, errors.New("a showdown needs at least two players")
+	}
+	if len(board) < 3 || len(board) > 5 {
+		return Showdown{}, fmt.Errorf("%w: the board has 3 to 5 cards", ErrInvalidCount)
+	}
+	all := append([]string(nil), board...)
+	ids := make(map[string]bool)
+	for _, player := range players {
+		if player.Id == "" {
+			return Showdown{}, errors.New("every player needs an id")
+		}
+		if ids[player.Id] {
+			return Showdown{}, fmt.Errorf("player %q is listed more than once", player.Id)
+		}
+		ids[player.Id] = true
+		if len(player.Cards) != 2 {
+			return Showdown{}, fmt.Errorf("%w: player %q must hold 2 cards", ErrInvalidCount, player.Id)
+		}
+		all = append(all, player.Cards...)
+	}
+	canonical, err := ParseCardCodes(strings.Join(all, ","), DeckOptions{}.CardCodes())
+	if err != nil {
+		return Showdown{}, err
+	}
+
+	boardCodes := canonical[:len(board)]
+	next := len(board)
+	var showdown Showdown
+	for _, player := range players {
+		hole := canonical[next : next+len(player.Cards)]
+		next += len(player.Cards)
+		result, err := evaluateCodes(append(append([]string(nil), boardCodes...), hole...))
+		if err != nil {
+			return Showdown{}, err
+		}
+		showdown.Hands = append(showdown.Hands, PlayerHand{Player: player.Id, HandResult: result})
+	}
+	sort.SliceStable(showdown.Hands, func(i, j int) bool {
+		return showdown.Hands[i].Rank > showdown.Hands[j].Rank
+	})
+
+	best := showdown.Hands[0]
+	for _, h := range showdown.Hands {
+		if h.Rank == best.Rank {
+			showdown.Winners = append(showdown.Winners, h.Player)
+			continue
+		}
+		showdown.Reasons = append(showdown.Reasons,
+			fmt.Sprintf("%s beats %s: %s", best.Player, h.Player, hand.Explain(best.result, h.result)))
+	}
+	showdown.Split = len(showdown.Winners) > 1
+	if showdown.Split {
+		showdown.Reasons = append([]string{
+			fmt.Sprintf("%s split the pot with %s", joinNames(showdown.Winners), best.Description),
+		}, showdown.Reasons...)
+	}
+	return showdown, nil
+}
+
+// joinNames lists names as "a, b and c".
+func joinNames(names []string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
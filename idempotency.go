@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+var ErrIdempotencyKeyInUse = errors.New("a request with this idempotency key is still in progress")
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// DefaultIdempotencyWindow is how long a response is replayed for when no
+// window is configured.
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// idempotencyLockTimeout bounds how long a key stays reserved by a request
+// that never finished, e.g. because the server stopped halfway through it.
+const idempotencyLockTimeout = time.Minute
+
+const maxIdempotencyKeyLength = 255
+
+// StoredResponse is the first response given to a request carrying an
+// Idempotency-Key. Its Status is 0 while that request is still in progress.
+type StoredResponse struct {
+	Id          string    `bson:"_id"`
+	RequestHash string    `bson:"request_hash"`
+	Status      int       `bson:"status"`
+	ContentType string    `bson:"content_type"`
+	Body        []byte    `bson:"body"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// responseRecorder keeps a copy of everything written to the client.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+func hashParts(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Idempotent replays the first response to a request carrying an
+// Idempotency-Key header to every retry with the same key, on the same path,
+// for window. Keys are scoped to the path, so a key used on one deck's draw
+// endpoint is independent of the same key on another deck, and to the caller's
+// API key, as responses show privileged callers more than everyone else.
+// Responses with a 5xx status are not kept, so those requests can be retried.
+func Idempotent(store DeckStore, window time.Duration) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		key := context.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			context.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			respondError(context, http.StatusBadRequest, errors.New("idempotency key must not be longer than 255 characters"))
+			return
+		}
+		var body []byte
+		if context.Request.Body != nil {
+			var err error
+			if body, err = io.ReadAll(context.Request.Body); err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+			context.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		method := []byte(context.Request.Method)
+		id := hashParts(method, []byte(context.Request.URL.Path), []byte(requestKey(context)), []byte(key))
+		requestHash := hashParts(method, []byte(context.Request.URL.RequestURI()), body)
+		lock := idempotencyLockTimeout
+		if window < lock {
+			lock = window
+		}
+		stored, reserved, err := store.ReserveResponse(StoredResponse{
+			Id:          id,
+			RequestHash: requestHash,
+			ExpiresAt:   time.Now().Add(lock),
+		})
+		if err != nil {
+			respondError(context, http.StatusInternalServerError, err)
+			return
+		}
+		if !reserved {
+			switch {
+			case stored.RequestHash != requestHash:
+				respondError(context, http.StatusUnprocessableEntity, ErrIdempotencyKeyReused)
+			case stored.Status == 0:
+				respondError(context, http.StatusConflict, ErrIdempotencyKeyInUse)
+			default:
+				context.Header("Idempotent-Replayed", "true")
+				context.Data(stored.Status, stored.ContentType, stored.Body)
+				context.Abort()
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: context.Writer}
+		context.Writer = recorder
+		context.Next()
+
+		if recorder.Status() >= http.StatusInternalServerError {
+			err = store.DeleteResponse(id)
+		} else {
+			err = store.SaveResponse(StoredResponse{
+				Id:          id,
+				RequestHash: requestHash,
+				Status:      recorder.Status(),
+				ContentType: recorder.Header().Get("Content-Type"),
+				Body:        recorder.body.Bytes(),
+				ExpiresAt:   time.Now().Add(window),
+			})
+		}
+		if err != nil {
+			log.Printf("failed to store response for idempotency key: %v", err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrDeckArchived = errors.New("deck is archived")
+
+// expired reports whether the deck's time to live has run out at now. Expired
+// decks are treated as gone even before a store gets around to removing them.
+func (d Deck) expired(now time.Time) bool {
+	return d.ExpiresAt != nil && !d.ExpiresAt.After(now)
+}
+
+// applyUpdate runs update on deck unless the deck is archived and stays so,
+// which leaves restoring the deck as the only change allowed to it, and
+// stamps the deck as updated.
+func applyUpdate(deck *Deck, update func(deck *Deck) error) error {
+	archived := deck.ArchivedAt != nil
+	if err := update(deck); err != nil {
+		return err
+	}
+	if archived && deck.ArchivedAt != nil {
+		return ErrDeckArchived
+	}
+	deck.UpdatedAt = timestamp()
+	return nil
+}
+
+// ArchiveDeck hides the deck from ListDecks and freezes it, while it can still
+// be opened and revealed.
+func ArchiveDeck(store DeckStore, deckId string) (Deck, error) {
+	deck, err := store.UpdateDeck(deckId, func(deck *Deck) error {
+		now := timestamp()
+		deck.ArchivedAt = &now
+		return nil
+	})
+	deck.Cards = nil
+	return deck, err
+}
+
+// RestoreDeck undoes ArchiveDeck.
+func RestoreDeck(store DeckStore, deckId string) (Deck, error) {
+	deck, err := store.UpdateDeck(deckId, func(deck *Deck) error {
+		if deck.ArchivedAt == nil {
+			return errors.New("deck is not archived")
+		}
+		deck.ArchivedAt = nil
+		return nil
+	})
+	deck.Cards = nil
+	return deck, err
+}
+
+func DeleteDeck(store DeckStore, deckId string) error {
+	return store.DeleteDeck(deckId)
+}
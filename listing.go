@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const MaxOwnerLength = 128
+const MaxTagsPerDeck = 16
+
+// DefaultListLimit and MaxListLimit bound how many decks a page holds.
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,64}$`)
+
+const MaxLabelsPerDeck = 32
+const maxLabelValueLength = 256
+
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+func ValidateTag(tag string) error {
+	if !tagPattern.MatchString(tag) {
+		return fmt.Errorf("invalid tag %q, use up to 64 letters, digits, '-', '_', '.' or ':'", tag)
+	}
+	return nil
+}
+
+// ValidateLabels checks label keys, which become document keys in the Mongo
+// store, and the size of their values.
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > MaxLabelsPerDeck {
+		return fmt.Errorf("a deck can have at most %d labels", MaxLabelsPerDeck)
+	}
+	for key, value := range labels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid label %q, use up to 64 letters, digits, '-', '_' or '.'", key)
+		}
+		if len(value) > maxLabelValueLength {
+			return fmt.Errorf("label %q must not be longer than %d characters", key, maxLabelValueLength)
+		}
+	}
+	return nil
+}
+
+// DeckFilter narrows down the decks ListDecks returns. Zero fields match
+// every deck.
+type DeckFilter struct {
+	Shuffled     *bool
+	MinRemaining *int
+	MaxRemaining *int
+	CreatedAfter time.Time
+	Owner        string
+	Tag          string
+	// Archived lists archived decks instead of the others.
+	Archived bool
+}
+
+func (f DeckFilter) Validate() error {
+	if f.MinRemaining != nil && *f.MinRemaining < 0 || f.MaxRemaining != nil && *f.MaxRemaining < 0 {
+		return errors.New("remaining must not be negative")
+	}
+	if f.MinRemaining != nil && f.MaxRemaining != nil && *f.MinRemaining > *f.MaxRemaining {
+		return errors.New("min_remaining must not be greater than max_remaining")
+	}
+	if f.Tag != "" {
+		return ValidateTag(f.Tag)
+	}
+	return nil
+}
+
+// matches reports whether deck passes the filter. Stores that cannot query
+// for the filter themselves use it to give the same results as the Mongo store.
+func (f DeckFilter) matches(deck Deck) bool {
+	if (deck.ArchivedAt != nil) != f.Archived {
+		return false
+	}
+	if f.Shuffled != nil && deck.Shuffled != *f.Shuffled {
+		return false
+	}
+	if f.MinRemaining != nil && deck.Remaining < *f.MinRemaining {
+		return false
+	}
+	if f.MaxRemaining != nil && deck.Remaining > *f.MaxRemaining {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && !deck.CreatedAt.After(f.CreatedAfter) {
+		return false
+	}
+	if f.Owner != "" && deck.Owner != f.Owner {
+		return false
+	}
+	if f.Tag != "" {
+		tagged := false
+		for _, tag := range deck.Tags {
+			tagged = tagged || tag == f.Tag
+		}
+		if !tagged {
+			return false
+		}
+	}
+	return true
+}
+
+// DeckCursor is where a page of ListDecks ends. Decks are listed by creation
+// time and then by id, so the cursor holds both of the last deck on the page.
+type DeckCursor struct {
+	CreatedAt time.Time
+	DeckId    string
+}
+
+// precedes reports whether the cursor comes before deck in listing order.
+func (c DeckCursor) precedes(deck Deck) bool {
+	if !deck.CreatedAt.Equal(c.CreatedAt) {
+		return deck.CreatedAt.After(c.CreatedAt)
+	}
+	return deck.DeckId > c.DeckId
+}
+
+func (c DeckCursor) String() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixMilli(), 10) + "," + c.DeckId
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func ParseDeckCursor(cursor string) (DeckCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return DeckCursor{}, ErrInvalidCursor
+	}
+	millis, deckId, found := strings.Cut(string(raw), ",")
+	if !found {
+		return DeckCursor{}, ErrInvalidCursor
+	}
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return DeckCursor{}, ErrInvalidCursor
+	}
+	createdAt := time.UnixMilli(ms).UTC()
+	if createdAt.IsZero() {
+		// decks created before timestamps existed have none
+		createdAt = time.Time{}
+	}
+	return DeckCursor{CreatedAt: createdAt, DeckId: deckId}, nil
+}
+
+// DeckPage is one page of ListDecks. NextCursor is empty on the last page.
+type DeckPage struct {
+	Decks      []Deck `json:"decks"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListDecks returns up to limit decks matching filter, without their cards,
+// starting after cursor or from the first deck when cursor is empty.
+func ListDecks(store DeckStore, filter DeckFilter, cursor string, limit int) (DeckPage, error) {
+	if limit < 1 || limit > MaxListLimit {
+		return DeckPage{}, fmt.Errorf("limit must be between 1 and %d", MaxListLimit)
+	}
+	if err := filter.Validate(); err != nil {
+		return DeckPage{}, err
+	}
+	var after *DeckCursor
+	if cursor != "" {
+		parsed, err := ParseDeckCursor(cursor)
+		if err != nil {
+			return DeckPage{}, err
+		}
+		after = &parsed
+	}
+	// one deck more than asked for tells whether there is a next page
+	decks, err := store.ListDecks(filter, after, limit+1)
+	if err != nil {
+		return DeckPage{}, err
+	}
+	page := DeckPage{Decks: decks}
+	if len(decks) > limit {
+		page.Decks = decks[:limit]
+		last := page.Decks[limit-1]
+		page.NextCursor = DeckCursor{CreatedAt: last.CreatedAt, DeckId: last.DeckId}.String()
+	}
+	for i := range page.Decks {
+		page.Decks[i].Cards = nil
+		page.Decks[i].Piles = nil
+		if page.Decks[i].Composition == "" {
+			page.Decks[i].Composition = StandardComposition
+		}
+	}
+	if page.Decks == nil {
+		page.Decks = []Deck{}
+	}
+	return page, nil
+}
@@ -1,78 +1,642 @@
 package main
 
 import (
-	"context"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
-func SetupRouter() *gin.Engine {
+// legacyDrawDeprecation is the Deprecation header (RFC 9745) sent by
+// GET /decks/:deckId/cards/count/:count, the date POST /decks/:deckId/draw
+// replaced it.
+const legacyDrawDeprecation = "@1792195200"
+
+// drawRequest is the JSON body of POST /decks/:deckId/draw.
+type drawRequest struct {
+	Count    int      `json:"count"`
+	Position string   `json:"position"`
+	Index    int      `json:"index"`
+	Codes    []string `json:"codes"`
+	Pile     string   `json:"pile"`
+}
+
+// handRequest is the JSON body of POST /hands/evaluate.
+type handRequest struct {
+	Cards []string `json:"cards"`
+}
+
+// showdownRequest is the JSON body of POST /hands/showdown.
+type showdownRequest struct {
+	Board   []string         `json:"board"`
+	Players []ShowdownPlayer `json:"players"`
+}
+
+// rangeRequest is the JSON body of POST /hands/range.
+type rangeRequest struct {
+	Range string   `json:"range"`
+	Dead  []string `json:"dead"`
+}
+
+// tableRequest is the JSON body of POST /tables.
+type tableRequest struct {
+	Seats   int      `json:"seats"`
+	Players []string `json:"players"`
+}
+
+// seatRequest is the JSON body of PUT /tables/:tableId/seats/:seat.
+type seatRequest struct {
+	Player string `json:"player"`
+}
+
+// equityRequest is the JSON body of POST /hands/equity.
+type equityRequest struct {
+	Board      []string       `json:"board"`
+	Players    []EquityPlayer `json:"players"`
+	Iterations int            `json:"iterations"`
+	// Budget is a duration such as "500ms".
+	Budget string `json:"budget"`
+	Seed   string `json:"seed"`
+}
+
+// RouterOption configures optional parts of the router.
+type RouterOption func(config *routerConfig)
+
+type routerConfig struct {
+	auth              *Authorizer
+	idempotencyWindow time.Duration
+}
+
+// WithAuthorizer guards privileged endpoints with auth. Without it no API key
+// is accepted and those endpoints always answer 401 or 403.
+func WithAuthorizer(auth *Authorizer) RouterOption {
+	return func(config *routerConfig) {
+		config.auth = auth
+	}
+}
+
+// WithIdempotencyWindow sets how long responses to requests carrying an
+// Idempotency-Key are replayed, DefaultIdempotencyWindow by default.
+func WithIdempotencyWindow(window time.Duration) RouterOption {
+	return func(config *routerConfig) {
+		config.idempotencyWindow = window
+	}
+}
+
+func SetupRouter(store DeckStore, options ...RouterOption) *gin.Engine {
+	config := routerConfig{auth: &Authorizer{}, idempotencyWindow: DefaultIdempotencyWindow}
+	for _, option := range options {
+		option(&config)
+	}
 	r := gin.Default()
+	idempotent := Idempotent(store, config.idempotencyWindow)
+	// decks dealt by a table are changed only through the table
+	tableDecks := GuardTableDecks(store)
+	// respondDeck answers with deck, leaving out the cards left in it and in its
+	// piles unless the caller may peek, as their order is as privileged as a peek
+	respondDeck := func(context *gin.Context, status int, deck Deck) {
+		if !config.auth.Granted(context, PermissionPeek) {
+			deck.Conceal()
+		}
+		context.JSON(status, deck)
+	}
 
-	r.POST("/decks", func(context *gin.Context) {
+	r.POST("/decks", idempotent, func(context *gin.Context) {
 		var shuffled bool
-		var requestedCards []string
 		var err error
 		shuffleString, exists := context.GetQuery("shuffle")
 		if !exists {
 			shuffled = false
 		} else {
 			if shuffled, err = strconv.ParseBool(shuffleString); err != nil {
-				context.JSON(http.StatusBadRequest, err.Error())
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+		}
+		decks := 1
+		if decksString, exists := context.GetQuery("decks"); exists {
+			if decks, err = strconv.Atoi(decksString); err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+		}
+		var jokers int
+		if jokersString, exists := context.GetQuery("jokers"); exists {
+			if jokers, err = strconv.Atoi(jokersString); err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+		}
+		opts := DeckOptions{
+			Shuffled:    shuffled,
+			Decks:       decks,
+			Composition: context.Query("composition"),
+			Jokers:      jokers,
+			ShuffleMode: context.Query("shuffle_mode"),
+			Seed:        context.Query("seed"),
+			Owner:       context.Query("owner"),
+		}
+		if tagsString := context.Query("tags"); tagsString != "" {
+			opts.Tags = strings.Split(tagsString, ",")
+		}
+		if labelsString := context.Query("labels"); labelsString != "" {
+			opts.Labels = make(map[string]string)
+			for _, label := range strings.Split(labelsString, ",") {
+				key, value, found := strings.Cut(label, "=")
+				if !found {
+					respondError(context, http.StatusBadRequest, fmt.Errorf("invalid label %q, expected key=value", label))
+					return
+				}
+				opts.Labels[key] = value
+			}
+		}
+		if ttlString, exists := context.GetQuery("ttl"); exists {
+			if opts.TTL, err = time.ParseDuration(ttlString); err != nil {
+				respondError(context, http.StatusBadRequest, err)
 				return
 			}
 		}
-		requestedCardsString, exists := context.GetQuery("cards")
-		if exists {
-			requestedCards = strings.Split(requestedCardsString, ",")
+		if decks < 1 {
+			respondError(context, http.StatusBadRequest, fmt.Errorf("decks must be between 1 and %d", MaxDecksPerShoe))
+			return
+		}
+		if err = opts.Validate(); err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		if requestedCardsString, exists := context.GetQuery("cards"); exists {
+			if opts.Cards, err = ParseCardCodes(requestedCardsString, opts.CardCodes()); err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
 		}
 
-		result, err := CreateDeck(shuffled, requestedCards...)
+		result, err := CreateDeck(store, opts)
 		if err != nil {
-			context.JSON(http.StatusInternalServerError, err.Error())
+			respondError(context, http.StatusInternalServerError, err)
 			return
 		}
-		context.JSON(http.StatusCreated, result)
+		respondDeck(context, http.StatusCreated, result)
+	})
+
+	r.GET("/decks", func(context *gin.Context) {
+		var filter DeckFilter
+		var err error
+		if shuffledString, exists := context.GetQuery("shuffled"); exists {
+			shuffled, err := strconv.ParseBool(shuffledString)
+			if err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+			filter.Shuffled = &shuffled
+		}
+		if minString, exists := context.GetQuery("min_remaining"); exists {
+			min, err := strconv.Atoi(minString)
+			if err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+			filter.MinRemaining = &min
+		}
+		if maxString, exists := context.GetQuery("max_remaining"); exists {
+			max, err := strconv.Atoi(maxString)
+			if err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+			filter.MaxRemaining = &max
+		}
+		if createdAfterString, exists := context.GetQuery("created_after"); exists {
+			if filter.CreatedAfter, err = time.Parse(time.RFC3339, createdAfterString); err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+		}
+		filter.Owner = context.Query("owner")
+		filter.Tag = context.Query("tag")
+		if archivedString, exists := context.GetQuery("archived"); exists {
+			if filter.Archived, err = strconv.ParseBool(archivedString); err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+		}
+		limit, err := strconv.Atoi(context.DefaultQuery("limit", strconv.Itoa(DefaultListLimit)))
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := ListDecks(store, filter, context.Query("cursor"), limit)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		context.JSON(http.StatusOK, result)
 	})
 
 	r.GET("/decks/:deckId", func(context *gin.Context) {
 		deckId := context.Param("deckId")
 		_, err := uuid.Parse(deckId)
 		if err != nil {
-			context.JSON(http.StatusBadRequest, err.Error())
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := OpenDeck(store, deckId)
+		if err != nil {
+			respondError(context, http.StatusInternalServerError, err)
+			return
+		}
+		respondDeck(context, http.StatusOK, result)
+	})
+
+	r.DELETE("/decks/:deckId", tableDecks, func(context *gin.Context) {
+		deckId := context.Param("deckId")
+		_, err := uuid.Parse(deckId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		if err = DeleteDeck(store, deckId); err != nil {
+			respondError(context, http.StatusInternalServerError, err)
+			return
+		}
+		context.Status(http.StatusNoContent)
+	})
+
+	r.POST("/decks/:deckId/archive", tableDecks, func(context *gin.Context) {
+		deckId := context.Param("deckId")
+		_, err := uuid.Parse(deckId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
 			return
 		}
 
-		result, err := OpenDeck(deckId)
+		result, err := ArchiveDeck(store, deckId)
 		if err != nil {
-			context.JSON(http.StatusNotFound, err.Error())
+			respondError(context, http.StatusInternalServerError, err)
+			return
+		}
+		respondDeck(context, http.StatusOK, result)
+	})
+
+	r.POST("/decks/:deckId/restore", tableDecks, func(context *gin.Context) {
+		deckId := context.Param("deckId")
+		_, err := uuid.Parse(deckId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := RestoreDeck(store, deckId)
+		if err != nil {
+			respondError(context, http.StatusConflict, err)
+			return
+		}
+		respondDeck(context, http.StatusOK, result)
+	})
+
+	r.GET("/decks/:deckId/original", func(context *gin.Context) {
+		deckId := context.Param("deckId")
+		_, err := uuid.Parse(deckId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := ReplayDeck(store, deckId, context.Query("seed"))
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		context.JSON(http.StatusOK, gin.H{
+			"cards": result,
+		})
+	})
+
+	r.POST("/decks/:deckId/close", tableDecks, func(context *gin.Context) {
+		deckId := context.Param("deckId")
+		_, err := uuid.Parse(deckId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := CloseDeck(store, deckId)
+		if err != nil {
+			respondError(context, http.StatusInternalServerError, err)
+			return
+		}
+		result.Cards = nil
+		respondDeck(context, http.StatusOK, result)
+	})
+
+	r.GET("/decks/:deckId/reveal", func(context *gin.Context) {
+		deckId := context.Param("deckId")
+		_, err := uuid.Parse(deckId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := RevealDeck(store, deckId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
 			return
 		}
 		context.JSON(http.StatusOK, result)
 	})
 
-	r.GET("/decks/:deckId/cards/count/:count", func(context *gin.Context) {
+	r.POST("/decks/:deckId/cards/return", tableDecks, func(context *gin.Context) {
+		deckId := context.Param("deckId")
+		_, err := uuid.Parse(deckId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		position := context.DefaultQuery("position", ReturnTop)
+		if err = validateReturnPosition(position); err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		cards, err := ParseCards(context.Query("cards"))
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := ReturnCards(store, deckId, cards, position)
+		if err != nil {
+			respondError(context, http.StatusConflict, err)
+			return
+		}
+		respondDeck(context, http.StatusOK, result)
+	})
+
+	r.GET("/decks/:deckId/piles/:pile", config.auth.Require(PermissionPeek), tableDecks, func(context *gin.Context) {
+		deckId := context.Param("deckId")
+		_, err := uuid.Parse(deckId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		pile := context.Param("pile")
+		if err = ValidatePileName(pile); err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := ListPile(store, deckId, pile)
+		if err != nil {
+			respondError(context, http.StatusInternalServerError, err)
+			return
+		}
+		context.JSON(http.StatusOK, gin.H{
+			"pile":      pile,
+			"remaining": len(result),
+			"cards":     result,
+		})
+	})
+
+	r.POST("/decks/:deckId/piles/:pile/cards", tableDecks, func(context *gin.Context) {
+		deckId := context.Param("deckId")
+		_, err := uuid.Parse(deckId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		pile := context.Param("pile")
+		if err = ValidatePileName(pile); err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		from := context.Query("from")
+		if from != "" {
+			if err = ValidatePileName(from); err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		var count int
+		var codes []Card
+		if codesString, exists := context.GetQuery("cards"); exists {
+			if codes, err = ParseCards(codesString); err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+		} else if count, err = strconv.Atoi(context.DefaultQuery("count", "1")); err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := MoveCards(store, deckId, pile, from, count, codes)
+		if err != nil {
+			respondError(context, http.StatusConflict, err)
+			return
+		}
+		context.JSON(http.StatusOK, gin.H{
+			"pile":  pile,
+			"cards": result,
+		})
+	})
+
+	r.POST("/decks/:deckId/piles/:pile/shuffle", tableDecks, func(context *gin.Context) {
+		deckId := context.Param("deckId")
+		_, err := uuid.Parse(deckId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		pile := context.Param("pile")
+		if err = ValidatePileName(pile); err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := ShufflePileIntoDeck(store, deckId, pile)
+		if err != nil {
+			respondError(context, http.StatusConflict, err)
+			return
+		}
+		respondDeck(context, http.StatusOK, result)
+	})
+
+	r.POST("/decks/:deckId/shuffle", tableDecks, func(context *gin.Context) {
+		deckId := context.Param("deckId")
+		_, err := uuid.Parse(deckId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		mode := context.DefaultQuery("mode", ReshuffleSecure)
+		riffles, err := strconv.Atoi(context.DefaultQuery("riffles", strconv.Itoa(DefaultRiffles)))
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		includePiles, err := strconv.ParseBool(context.DefaultQuery("include_piles", "false"))
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		if err = validateReshuffle(mode, riffles); err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := ShuffleDeck(store, deckId, mode, riffles, includePiles)
+		if err != nil {
+			respondError(context, http.StatusConflict, err)
+			return
+		}
+		respondDeck(context, http.StatusOK, result)
+	})
+
+	r.POST("/decks/:deckId/cut", tableDecks, func(context *gin.Context) {
+		deckId := context.Param("deckId")
+		_, err := uuid.Parse(deckId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		at, err := strconv.Atoi(context.Query("at"))
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := CutDeck(store, deckId, at)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		respondDeck(context, http.StatusOK, result)
+	})
+
+	r.GET("/decks/:deckId/cards/peek/:count", config.auth.Require(PermissionPeek), func(context *gin.Context) {
+		deckId := context.Param("deckId")
+		_, err := uuid.Parse(deckId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		count, err := strconv.Atoi(context.Param("count"))
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := PeekCards(store, deckId, count)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		context.JSON(http.StatusOK, gin.H{
+			"cards": result,
+		})
+	})
+
+	r.POST("/decks/:deckId/draw", tableDecks, idempotent, func(context *gin.Context) {
+		deckId := context.Param("deckId")
+		_, err := uuid.Parse(deckId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		var body drawRequest
+		if err = context.ShouldBindJSON(&body); err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		opts := DrawOptions{
+			Count:    body.Count,
+			Position: body.Position,
+			Index:    body.Index,
+			Pile:     body.Pile,
+		}
+		if len(body.Codes) > 0 {
+			if opts.Codes, err = ParseCards(strings.Join(body.Codes, ",")); err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+			if opts.Count == 0 {
+				opts.Count = len(opts.Codes)
+			}
+		}
+		if err = opts.Validate(); err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := DrawCards(store, deckId, opts)
+		if err != nil {
+			respondError(context, http.StatusConflict, err)
+			return
+		}
+		response := gin.H{
+			"cards": result,
+		}
+		if opts.Pile != "" {
+			response["pile"] = opts.Pile
+		}
+		context.JSON(http.StatusOK, response)
+	})
+
+	r.GET("/decks/:deckId/cards/count/:count", tableDecks, idempotent, func(context *gin.Context) {
+		context.Header("Deprecation", legacyDrawDeprecation)
+		context.Header("Link", fmt.Sprintf(`</decks/%s/draw>; rel="successor-version"`, context.Param("deckId")))
 		deckId := context.Param("deckId")
 		_, err := uuid.Parse(deckId)
 		if err != nil {
-			context.JSON(http.StatusBadRequest, err.Error())
+			respondError(context, http.StatusBadRequest, err)
 			return
 		}
 
 		count, err := strconv.Atoi(context.Param("count"))
 		if err != nil {
-			context.JSON(http.StatusBadRequest, err.Error())
+			respondError(context, http.StatusBadRequest, err)
 			return
 		}
 
-		result, err := DrawCards(deckId, count)
+		opts := DrawOptions{
+			Count:    count,
+			Position: context.Query("position"),
+		}
+		if indexString, exists := context.GetQuery("index"); exists {
+			if opts.Index, err = strconv.Atoi(indexString); err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+		}
+		if codesString, exists := context.GetQuery("codes"); exists {
+			if opts.Codes, err = ParseCards(codesString); err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+		}
+		if opts.Position != "" || len(opts.Codes) > 0 {
+			if err = opts.Validate(); err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		result, err := DrawCards(store, deckId, opts)
 		if err != nil {
-			context.JSON(http.StatusNotFound, err.Error())
+			respondError(context, http.StatusBadRequest, err)
 			return
 		}
 		context.JSON(http.StatusOK, gin.H{
@@ -80,6 +644,201 @@ func SetupRouter() *gin.Engine {
 		})
 	})
 
+	r.POST("/hands/evaluate", func(context *gin.Context) {
+		var body handRequest
+		if err := context.ShouldBindJSON(&body); err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		result, err := EvaluateHand(body.Cards)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		context.JSON(http.StatusOK, result)
+	})
+
+	r.POST("/hands/showdown", func(context *gin.Context) {
+		var body showdownRequest
+		if err := context.ShouldBindJSON(&body); err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		result, err := CompareHands(body.Board, body.Players)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		context.JSON(http.StatusOK, result)
+	})
+
+	r.POST("/hands/equity", func(context *gin.Context) {
+		var body equityRequest
+		if err := context.ShouldBindJSON(&body); err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		opts := EquityOptions{
+			Board:      body.Board,
+			Players:    body.Players,
+			Iterations: body.Iterations,
+			Seed:       body.Seed,
+		}
+		if body.Budget != "" {
+			var err error
+			if opts.Budget, err = time.ParseDuration(body.Budget); err != nil {
+				respondError(context, http.StatusBadRequest, err)
+				return
+			}
+		}
+		result, err := CalculateEquity(opts)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		context.JSON(http.StatusOK, result)
+	})
+
+	r.POST("/hands/range", func(context *gin.Context) {
+		var body rangeRequest
+		if err := context.ShouldBindJSON(&body); err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		result, err := ExpandRange(body.Range, body.Dead)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		context.JSON(http.StatusOK, result)
+	})
+
+	r.POST("/tables", func(context *gin.Context) {
+		var body tableRequest
+		if err := context.ShouldBindJSON(&body); err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		result, err := CreateTable(store, TableOptions{Seats: body.Seats, Players: body.Players})
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		context.JSON(http.StatusCreated, result)
+	})
+
+	r.GET("/tables/:tableId", func(context *gin.Context) {
+		tableId := context.Param("tableId")
+		_, err := uuid.Parse(tableId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := OpenTable(store, tableId)
+		if err != nil {
+			respondError(context, http.StatusInternalServerError, err)
+			return
+		}
+		if !config.auth.Granted(context, PermissionPeek) {
+			result.Conceal()
+		}
+		context.JSON(http.StatusOK, result)
+	})
+
+	r.POST("/tables/:tableId/advance", idempotent, func(context *gin.Context) {
+		tableId := context.Param("tableId")
+		_, err := uuid.Parse(tableId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := AdvanceTable(store, tableId)
+		if err != nil {
+			respondError(context, http.StatusInternalServerError, err)
+			return
+		}
+		if !config.auth.Granted(context, PermissionPeek) {
+			result.Conceal()
+		}
+		context.JSON(http.StatusOK, result)
+	})
+
+	r.PUT("/tables/:tableId/seats/:seat", func(context *gin.Context) {
+		tableId := context.Param("tableId")
+		_, err := uuid.Parse(tableId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		seat, err := strconv.Atoi(context.Param("seat"))
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		var body seatRequest
+		if err = context.ShouldBindJSON(&body); err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := SitDown(store, tableId, seat, body.Player)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		if !config.auth.Granted(context, PermissionPeek) {
+			result.Conceal()
+		}
+		context.JSON(http.StatusOK, result)
+	})
+
+	r.GET("/tables/:tableId/seats/:seat/cards", func(context *gin.Context) {
+		tableId := context.Param("tableId")
+		_, err := uuid.Parse(tableId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		seat, err := strconv.Atoi(context.Param("seat"))
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := SeatCards(store, tableId, seat, requestKey(context))
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		context.JSON(http.StatusOK, result)
+	})
+
+	r.DELETE("/tables/:tableId/seats/:seat", func(context *gin.Context) {
+		tableId := context.Param("tableId")
+		_, err := uuid.Parse(tableId)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		seat, err := strconv.Atoi(context.Param("seat"))
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+
+		result, err := StandUp(store, tableId, seat)
+		if err != nil {
+			respondError(context, http.StatusBadRequest, err)
+			return
+		}
+		if !config.auth.Granted(context, PermissionPeek) {
+			result.Conceal()
+		}
+		context.JSON(http.StatusOK, result)
+	})
+
 	return r
 }
 
@@ -88,12 +847,27 @@ func main() {
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
-	r := SetupRouter()
-	SetupDb()
+	store, err := NewDeckStore(os.Getenv("DECK_STORE"))
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer func() {
-		if err := DbClient.Disconnect(context.TODO()); err != nil {
+		if err := store.Close(); err != nil {
 			panic(err)
 		}
 	}()
+	auth, err := NewAuthorizer(os.Getenv("API_KEYS"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	options := []RouterOption{WithAuthorizer(auth)}
+	if windowString := os.Getenv("IDEMPOTENCY_WINDOW"); windowString != "" {
+		window, err := time.ParseDuration(windowString)
+		if err != nil || window <= 0 {
+			log.Fatalf("invalid IDEMPOTENCY_WINDOW %q, expected a positive duration such as 24h", windowString)
+		}
+		options = append(options, WithIdempotencyWindow(window))
+	}
+	r := SetupRouter(store, options...)
 	r.Run() // listen and serve on 0.0.0.0:8080 (for windows "localhost:8080")
 }
@@ -1,20 +1,27 @@
 package main
 
 import (
+	"card-game/hand"
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestRouter(t *testing.T) {
 	_ = godotenv.Load("test.env")
-	router := SetupRouter()
-	SetupDb()
+	store := NewMemoryStore()
+	router := SetupRouter(store)
+	// dealerRouter serves the same decks to callers with a peek key, which see
+	// the cards of an opened deck
+	auth, _ := NewAuthorizer(testDealerKey + ":peek")
+	dealerRouter := SetupRouter(store, WithAuthorizer(auth))
 	t.Run("Create deck", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodPost, "/decks", nil)
@@ -127,11 +134,90 @@ func TestRouter(t *testing.T) {
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodPost, "/decks", nil)
 		q := req.URL.Query()
-		q.Add("cards", "random value")
+		q.Add("cards", "AH,ZZ,random value,ah,KD")
 		req.URL.RawQuery = q.Encode()
 
 		router.ServeHTTP(w, req)
 
+		var resBody struct {
+			ErrorResponse
+			Details CardCodeError `json:"details"`
+		}
+		if resBodyBytes := w.Body.Bytes(); resBodyBytes != nil {
+			if err := json.Unmarshal(resBodyBytes, &resBody); err != nil {
+				t.Error("Error while unmarshaling response body to ErrorResponse struct.")
+			}
+		}
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		if resBody.Code != "invalid_card_code" {
+			t.Errorf("Error code is incorrect. Expected: %v, actual: %v", "invalid_card_code", resBody.Code)
+		}
+
+		if strings.Join(resBody.Details.Invalid, ",") != "ZZ,random value" {
+			t.Errorf("Invalid codes should be listed. Expected: %v, actual: %v", "ZZ,random value", resBody.Details.Invalid)
+		}
+
+		if strings.Join(resBody.Details.Duplicates, ",") != "AH" {
+			t.Errorf("Duplicate codes should be listed. Expected: %v, actual: %v", "AH", resBody.Details.Duplicates)
+		}
+	})
+	t.Run("Create partial deck with cards outside the composition", func(t *testing.T) {
+		for _, query := range []url.Values{
+			{"cards": {"X1,KD"}},
+			{"cards": {"2H"}, "composition": {"euchre"}},
+		} {
+			if _, code := createTestDeck(t, router, query); code != http.StatusUnprocessableEntity {
+				t.Errorf("HTTP status code is incorrect for %v. expected: %v, actual: %v", query, http.StatusUnprocessableEntity, code)
+			}
+		}
+	})
+	t.Run("Create partial deck in requested order", func(t *testing.T) {
+		deck, code := createTestDeck(t, router, url.Values{"cards": {" kd, ah ,2c"}})
+		if code != http.StatusCreated {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusCreated, code)
+		}
+		var codes []string
+		for _, c := range openTestDeck(t, dealerRouter, deck.DeckId).Cards {
+			codes = append(codes, c.Code)
+		}
+		if strings.Join(codes, ",") != "KD,AH,2C" {
+			t.Errorf("Cards should keep the requested order. Expected: %v, actual: %v", "KD,AH,2C", codes)
+		}
+	})
+	t.Run("Create shoe", func(t *testing.T) {
+		//arrange
+		seedW := httptest.NewRecorder()
+		deckReq, _ := http.NewRequest(http.MethodPost, "/decks", nil)
+		q := deckReq.URL.Query()
+		q.Add("decks", "6")
+		deckReq.URL.RawQuery = q.Encode()
+		router.ServeHTTP(seedW, deckReq)
+		var seedBody Deck
+		if resBodyBytes := seedW.Body.Bytes(); resBodyBytes != nil {
+			if err := json.Unmarshal(resBodyBytes, &seedBody); err != nil {
+				t.Error("Error while unmarshaling response body to Deck struct.")
+			}
+		}
+
+		if seedW.Code != http.StatusCreated {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusCreated, seedW.Code)
+		}
+
+		if seedBody.Remaining != 6*52 {
+			t.Errorf("Shoe remaining cards should be 312. Expected: %v, actual: %v", 6*52, seedBody.Remaining)
+		}
+
+		//act
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s", seedBody.DeckId), nil)
+		req.Header.Set("Authorization", "Bearer "+testDealerKey)
+
+		dealerRouter.ServeHTTP(w, req)
+
 		var resBody Deck
 		if resBodyBytes := w.Body.Bytes(); resBodyBytes != nil {
 			if err := json.Unmarshal(resBodyBytes, &resBody); err != nil {
@@ -139,12 +225,564 @@ func TestRouter(t *testing.T) {
 			}
 		}
 
-		if w.Code != http.StatusCreated {
-			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusCreated, w.Code)
+		if resBody.Decks != 6 {
+			t.Errorf("Shoe should record its number of decks. Expected: %v, actual: %v", 6, resBody.Decks)
+		}
+
+		copies := make(map[Card]bool)
+		for _, c := range resBody.Cards {
+			copies[c] = true
+		}
+		if len(copies) != 6*52 {
+			t.Errorf("Every card in a shoe should be distinct by copy. Expected: %v, actual: %v", 6*52, len(copies))
+		}
+	})
+	t.Run("Create shoe with invalid query string", func(t *testing.T) {
+		for _, decks := range []string{"0", "9", "random value"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/decks", nil)
+			q := req.URL.Query()
+			q.Add("decks", decks)
+			req.URL.RawQuery = q.Encode()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("HTTP status code is incorrect for decks=%v. expected: %v, actual: %v", decks, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+	t.Run("Create deck with composition and jokers", func(t *testing.T) {
+		tests := []struct {
+			composition string
+			jokers      string
+			expected    int
+		}{
+			{"standard", "2", 54},
+			{"piquet", "0", 32},
+			{"euchre", "1", 25},
+			{"spanish", "0", 40},
+			{"pinochle", "0", 48},
+		}
+		for _, tt := range tests {
+			//arrange
+			seedW := httptest.NewRecorder()
+			deckReq, _ := http.NewRequest(http.MethodPost, "/decks", nil)
+			q := deckReq.URL.Query()
+			q.Add("composition", tt.composition)
+			q.Add("jokers", tt.jokers)
+			deckReq.URL.RawQuery = q.Encode()
+			router.ServeHTTP(seedW, deckReq)
+			var seedBody Deck
+			if resBodyBytes := seedW.Body.Bytes(); resBodyBytes != nil {
+				if err := json.Unmarshal(resBodyBytes, &seedBody); err != nil {
+					t.Error("Error while unmarshaling response body to Deck struct.")
+				}
+			}
+
+			if seedW.Code != http.StatusCreated {
+				t.Errorf("HTTP status code is incorrect for %v. expected: %v, actual: %v", tt.composition, http.StatusCreated, seedW.Code)
+			}
+
+			if seedBody.Remaining != tt.expected {
+				t.Errorf("Deck remaining cards is incorrect for %v. Expected: %v, actual: %v", tt.composition, tt.expected, seedBody.Remaining)
+			}
+
+			//act
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s", seedBody.DeckId), nil)
+			req.Header.Set("Authorization", "Bearer "+testDealerKey)
+
+			dealerRouter.ServeHTTP(w, req)
+
+			var resBody Deck
+			if resBodyBytes := w.Body.Bytes(); resBodyBytes != nil {
+				if err := json.Unmarshal(resBodyBytes, &resBody); err != nil {
+					t.Error("Error while unmarshaling response body to Deck struct.")
+				}
+			}
+
+			if resBody.Composition != tt.composition {
+				t.Errorf("Deck should report its composition. Expected: %v, actual: %v", tt.composition, resBody.Composition)
+			}
+
+			copies := make(map[Card]bool)
+			for _, c := range resBody.Cards {
+				copies[c] = true
+			}
+			if len(copies) != tt.expected {
+				t.Errorf("Every card in a %v deck should be distinct. Expected: %v, actual: %v", tt.composition, tt.expected, len(copies))
+			}
+		}
+	})
+	t.Run("Create deck with invalid composition or jokers", func(t *testing.T) {
+		for _, query := range []map[string]string{
+			{"composition": "tarot"},
+			{"jokers": "-1"},
+			{"jokers": "5"},
+			{"jokers": "random value"},
+		} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/decks", nil)
+			q := req.URL.Query()
+			for k, v := range query {
+				q.Add(k, v)
+			}
+			req.URL.RawQuery = q.Encode()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("HTTP status code is incorrect for %v. expected: %v, actual: %v", query, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+	t.Run("Create seeded deck", func(t *testing.T) {
+		seeded := url.Values{"shuffle": {"true"}, "seed": {"table-7-hand-42"}}
+		first, code := createTestDeck(t, router, seeded)
+		if code != http.StatusCreated {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusCreated, code)
+		}
+		second, _ := createTestDeck(t, router, seeded)
+		other, _ := createTestDeck(t, router, url.Values{"shuffle": {"true"}, "seed": {"table-7-hand-43"}})
+
+		if first.SeedHash == "" || first.SeedHash != second.SeedHash {
+			t.Errorf("Decks with the same seed should share a seed hash. Expected: %v, actual: %v", first.SeedHash, second.SeedHash)
+		}
+		firstCards := openTestDeck(t, dealerRouter, first.DeckId).Cards
+		secondCards := openTestDeck(t, dealerRouter, second.DeckId).Cards
+		otherCards := openTestDeck(t, dealerRouter, other.DeckId).Cards
+		if !isShuffled(firstCards) {
+			t.Error("Deck should be shuffled, please check the sequence of the cards.")
+		}
+		if !sameOrder(firstCards, secondCards) {
+			t.Error("Decks with the same seed should have the same order.")
+		}
+		if sameOrder(firstCards, otherCards) {
+			t.Error("Decks with different seeds should have different orders.")
+		}
+	})
+	t.Run("Create seeded deck without shuffle", func(t *testing.T) {
+		_, code := createTestDeck(t, router, url.Values{"seed": {"42"}})
+		if code != http.StatusBadRequest {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusBadRequest, code)
+		}
+	})
+	t.Run("Create securely shuffled deck", func(t *testing.T) {
+		deck, code := createTestDeck(t, router, url.Values{"shuffle": {"true"}, "shuffle_mode": {"secure"}})
+		if code != http.StatusCreated {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusCreated, code)
+		}
+		opened := openTestDeck(t, dealerRouter, deck.DeckId)
+		if opened.ShuffleMode != ShuffleModeSecure {
+			t.Errorf("Deck should record its shuffle mode. Expected: %v, actual: %v", ShuffleModeSecure, opened.ShuffleMode)
+		}
+		if !isShuffled(opened.Cards) {
+			t.Error("Deck should be shuffled, please check the sequence of the cards.")
+		}
+
+		for _, query := range []url.Values{
+			{"shuffle": {"true"}, "shuffle_mode": {"secure"}, "seed": {"42"}},
+			{"shuffle_mode": {"secure"}},
+			{"shuffle": {"true"}, "shuffle_mode": {"random value"}},
+		} {
+			if _, code := createTestDeck(t, router, query); code != http.StatusBadRequest {
+				t.Errorf("HTTP status code is incorrect for %v. expected: %v, actual: %v", query, http.StatusBadRequest, code)
+			}
+		}
+	})
+	t.Run("Replay original order", func(t *testing.T) {
+		//arrange
+		deck, _ := createTestDeck(t, router, url.Values{"shuffle": {"true"}, "seed": {"audit"}})
+		original := openTestDeck(t, dealerRouter, deck.DeckId).Cards
+		drawW := httptest.NewRecorder()
+		drawReq, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/cards/count/%d", deck.DeckId, 5), nil)
+		router.ServeHTTP(drawW, drawReq)
+
+		//act
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/original?seed=audit", deck.DeckId), nil)
+
+		router.ServeHTTP(w, req)
+
+		var resBody Deck
+		if resBodyBytes := w.Body.Bytes(); resBodyBytes != nil {
+			if err := json.Unmarshal(resBodyBytes, &resBody); err != nil {
+				t.Error("Error while unmarshaling response body to Deck struct.")
+			}
+		}
+
+		if w.Code != http.StatusOK {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusOK, w.Code)
+		}
+		if !sameOrder(original, resBody.Cards) {
+			t.Error("Replayed cards should match the order the deck was created with.")
+		}
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/original?seed=guess", deck.DeckId), nil)
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusBadRequest, w.Code)
+		}
+	})
+	t.Run("Replay generated seed once the deck is closed", func(t *testing.T) {
+		//arrange
+		deck, _ := createTestDeck(t, router, url.Values{"shuffle": {"true"}})
+		original := openTestDeck(t, dealerRouter, deck.DeckId).Cards
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/original", deck.DeckId), nil)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Replay without a seed should fail while the deck is open. expected: %v, actual: %v", http.StatusBadRequest, w.Code)
+		}
+
+		//act
+		closeW := httptest.NewRecorder()
+		closeReq, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/decks/%s/close", deck.DeckId), nil)
+		router.ServeHTTP(closeW, closeReq)
+		revealW := httptest.NewRecorder()
+		revealReq, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/reveal", deck.DeckId), nil)
+		router.ServeHTTP(revealW, revealReq)
+		var reveal Reveal
+		if err := json.Unmarshal(revealW.Body.Bytes(), &reveal); err != nil {
+			t.Error("Error while unmarshaling response body to Reveal struct.")
+		}
+
+		//assert
+		if reveal.Seed == "" || HashSeed(reveal.Seed) != deck.SeedHash {
+			t.Errorf("Reveal should disclose the seed matching %v, actual: %q", deck.SeedHash, reveal.Seed)
+		}
+		for _, query := range []string{"", "?seed=" + url.QueryEscape(reveal.Seed)} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/original%s", deck.DeckId, query), nil)
+			router.ServeHTTP(w, req)
+			var resBody Deck
+			if err := json.Unmarshal(w.Body.Bytes(), &resBody); err != nil {
+				t.Error("Error while unmarshaling response body to Deck struct.")
+			}
+			if w.Code != http.StatusOK || !sameOrder(original, resBody.Cards) {
+				t.Errorf("Closed deck should replay for %q, status: %v", query, w.Code)
+			}
+		}
+	})
+	t.Run("Reveal committed deck once exhausted", func(t *testing.T) {
+		//arrange
+		deck, _ := createTestDeck(t, router, url.Values{"shuffle": {"true"}, "decks": {"2"}})
+		if deck.Commitment == "" {
+			t.Fatal("Shuffled deck should come with a commitment.")
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/reveal", deck.DeckId), nil)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusConflict {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusConflict, w.Code)
+		}
+
+		var dealt []string
+		for len(dealt) < 104 {
+			drawW := httptest.NewRecorder()
+			drawReq, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/cards/count/%d", deck.DeckId, 8), nil)
+			router.ServeHTTP(drawW, drawReq)
+			var drawBody Deck
+			if err := json.Unmarshal(drawW.Body.Bytes(), &drawBody); err != nil {
+				t.Fatal("Error while unmarshaling response body to Deck struct.")
+			}
+			for _, c := range drawBody.Cards {
+				dealt = append(dealt, c.Code)
+			}
+		}
+
+		//act
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/reveal", deck.DeckId), nil)
+
+		router.ServeHTTP(w, req)
+
+		var resBody Reveal
+		if err := json.Unmarshal(w.Body.Bytes(), &resBody); err != nil {
+			t.Error("Error while unmarshaling response body to Reveal struct.")
+		}
+
+		if w.Code != http.StatusOK {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusOK, w.Code)
+		}
+		if !VerifyCommitment(deck.Commitment, resBody.Salt, resBody.Order) {
+			t.Error("Revealed salt and order should match the commitment.")
+		}
+		if strings.Join(dealt, ",") != strings.Join(resBody.Order, ",") {
+			t.Error("Revealed order should match the cards that were drawn.")
+		}
+	})
+	t.Run("Reveal closed deck", func(t *testing.T) {
+		//arrange
+		deck, _ := createTestDeck(t, router, url.Values{"shuffle": {"true"}})
+		closeW := httptest.NewRecorder()
+		closeReq, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/decks/%s/close", deck.DeckId), nil)
+		router.ServeHTTP(closeW, closeReq)
+		if closeW.Code != http.StatusOK {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusOK, closeW.Code)
+		}
+
+		//act
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/reveal", deck.DeckId), nil)
+
+		router.ServeHTTP(w, req)
+
+		var resBody Reveal
+		if err := json.Unmarshal(w.Body.Bytes(), &resBody); err != nil {
+			t.Error("Error while unmarshaling response body to Reveal struct.")
+		}
+
+		if w.Code != http.StatusOK {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusOK, w.Code)
+		}
+		if len(resBody.Order) != 52 || !VerifyCommitment(deck.Commitment, resBody.Salt, resBody.Order) {
+			t.Error("Revealed salt and order should match the commitment.")
+		}
+
+		drawW := httptest.NewRecorder()
+		drawReq, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/cards/count/%d", deck.DeckId, 1), nil)
+		router.ServeHTTP(drawW, drawReq)
+		if drawW.Code == http.StatusOK {
+			t.Error("Drawing from a closed deck should fail.")
+		}
+	})
+	t.Run("Return cards", func(t *testing.T) {
+		//arrange
+		deck, _ := createTestDeck(t, router, url.Values{"decks": {"2"}})
+		drawW := httptest.NewRecorder()
+		drawReq, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/cards/count/%d", deck.DeckId, 3), nil)
+		router.ServeHTTP(drawW, drawReq)
+
+		tests := []struct {
+			cards    string
+			position string
+			expected int
+		}{
+			{"KH", "shuffle", http.StatusOK},
+			{"kh,qh", "random", http.StatusConflict},
+			{"qh", "random", http.StatusOK},
+			{"JH", "top", http.StatusOK},
+			{"JH", "top", http.StatusConflict},
+			{"ZZ", "top", http.StatusUnprocessableEntity},
+			{"AS", "middle", http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			//act
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/decks/%s/cards/return?cards=%s&position=%s", deck.DeckId, tt.cards, tt.position), nil)
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("HTTP status code is incorrect for %v. expected: %v, actual: %v", tt.cards, tt.expected, w.Code)
+			}
+		}
+
+		opened := openTestDeck(t, dealerRouter, deck.DeckId)
+		if opened.Remaining != 104 || !opened.Shuffled {
+			t.Errorf("Every drawn card should be back in a reshuffled shoe. Expected: %v, actual: %v", 104, opened.Remaining)
+		}
+	})
+	t.Run("Deal to piles", func(t *testing.T) {
+		deck, _ := createTestDeck(t, router, url.Values{})
+		tests := []struct {
+			method   string
+			path     string
+			key      string
+			expected int
+		}{
+			{http.MethodPost, "/piles/burn/cards", "", http.StatusOK},
+			{http.MethodPost, "/piles/player1/cards?count=2", "", http.StatusOK},
+			{http.MethodPost, "/piles/discard/cards?from=player1&cards=qh", "", http.StatusOK},
+			{http.MethodPost, "/piles/discard/cards?from=player1&cards=QH", "", http.StatusConflict},
+			{http.MethodPost, "/piles/discard/cards?from=nobody", "", http.StatusNotFound},
+			{http.MethodPost, "/piles/discard/cards?from=discard", "", http.StatusConflict},
+			{http.MethodPost, "/piles/bad.name/cards", "", http.StatusBadRequest},
+			{http.MethodGet, "/piles/player1", "", http.StatusUnauthorized},
+			{http.MethodGet, "/piles/player1", testDealerKey, http.StatusOK},
+			{http.MethodGet, "/piles/nobody", testDealerKey, http.StatusNotFound},
+			{http.MethodPost, "/piles/discard/shuffle", "", http.StatusOK},
+			{http.MethodPost, "/piles/discard/shuffle", "", http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, fmt.Sprintf("/decks/%s%s", deck.DeckId, tt.path), nil)
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+
+			dealerRouter.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("HTTP status code is incorrect for %v %v. expected: %v, actual: %v", tt.method, tt.path, tt.expected, w.Code)
+			}
+		}
+
+		opened := openTestDeck(t, dealerRouter, deck.DeckId)
+		if opened.Remaining != 50 || len(opened.Piles["burn"]) != 1 || len(opened.Piles["player1"]) != 1 {
+			t.Errorf("Piles don't match expected counts, remaining: %v, piles: %v", opened.Remaining, opened.Piles)
+		}
+	})
+	t.Run("Shuffle and cut an existing deck", func(t *testing.T) {
+		deck, _ := createTestDeck(t, router, url.Values{})
+		pileW := httptest.NewRecorder()
+		pileReq, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/decks/%s/piles/discard/cards?count=4", deck.DeckId), nil)
+		router.ServeHTTP(pileW, pileReq)
+
+		tests := []struct {
+			path     string
+			expected int
+		}{
+			{"/cut?at=10", http.StatusOK},
+			{"/cut?at=48", http.StatusBadRequest},
+			{"/cut?at=random value", http.StatusBadRequest},
+			{"/shuffle", http.StatusOK},
+			{"/shuffle?mode=riffle&riffles=3", http.StatusOK},
+			{"/shuffle?mode=riffle&riffles=0", http.StatusBadRequest},
+			{"/shuffle?mode=overhand", http.StatusBadRequest},
+			{"/shuffle?include_piles=true", http.StatusOK},
+		}
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/decks/%s%s", deck.DeckId, tt.path), nil)
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("HTTP status code is incorrect for %v. expected: %v, actual: %v", tt.path, tt.expected, w.Code)
+			}
+		}
+
+		opened := openTestDeck(t, dealerRouter, deck.DeckId)
+		if !opened.Shuffled || opened.Remaining != 52 || len(opened.Piles) != 0 {
+			t.Errorf("Deck should be shuffled with its piles, shuffled: %v, remaining: %v", opened.Shuffled, opened.Remaining)
+		}
+	})
+	t.Run("List decks", func(t *testing.T) {
+		//arrange
+		owner := uuid.NewString()
+		for _, tags := range []string{"table-1", "table-2", "table-1,final"} {
+			if _, code := createTestDeck(t, router, url.Values{"owner": {owner}, "tags": {tags}}); code != http.StatusCreated {
+				t.Fatalf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusCreated, code)
+			}
+		}
+
+		//act
+		var page DeckPage
+		var listed int
+		query := url.Values{"owner": {owner}, "tag": {"table-1"}, "limit": {"1"}}
+		for i := 0; i < 3; i++ {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/decks?"+query.Encode(), nil)
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusOK, w.Code)
+			}
+			page = DeckPage{}
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Error("Error while unmarshaling response body to DeckPage struct.")
+			}
+			listed += len(page.Decks)
+			for _, deck := range page.Decks {
+				if deck.Owner != owner || len(deck.Cards) != 0 || deck.CreatedAt.IsZero() {
+					t.Errorf("Listed deck is incorrect, actual: %+v", deck)
+				}
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			query.Set("cursor", page.NextCursor)
 		}
 
-		if resBody.Remaining != 0 {
-			t.Errorf("Deck remaining should be 0. Expected: %v, actual: %v", 0, resBody.Remaining)
+		//assert
+		if listed != 2 {
+			t.Errorf("Decks tagged table-1 should be listed. expected: %v, actual: %v", 2, listed)
+		}
+		for _, query := range []string{"limit=0", "limit=101", "cursor=%25%25", "min_remaining=5&max_remaining=1", "created_after=yesterday", "tag=bad%20tag"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/decks?"+query, nil)
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("HTTP status code is incorrect for %v. expected: %v, actual: %v", query, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+	t.Run("Delete and archive decks", func(t *testing.T) {
+		deck, _ := createTestDeck(t, router, url.Values{})
+		tests := []struct {
+			method   string
+			path     string
+			expected int
+		}{
+			{http.MethodPost, "/archive", http.StatusOK},
+			{http.MethodPost, "/archive", http.StatusConflict},
+			{http.MethodGet, "/cards/count/1", http.StatusConflict},
+			{http.MethodGet, "", http.StatusOK},
+			{http.MethodPost, "/restore", http.StatusOK},
+			{http.MethodPost, "/restore", http.StatusConflict},
+			{http.MethodGet, "/cards/count/1", http.StatusOK},
+			{http.MethodDelete, "", http.StatusNoContent},
+			{http.MethodGet, "", http.StatusNotFound},
+			{http.MethodDelete, "", http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, fmt.Sprintf("/decks/%s%s", deck.DeckId, tt.path), nil)
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("HTTP status code is incorrect for %v %v. expected: %v, actual: %v", tt.method, tt.path, tt.expected, w.Code)
+			}
+		}
+	})
+	t.Run("Create deck with a time to live", func(t *testing.T) {
+		deck, code := createTestDeck(t, router, url.Values{"ttl": {"1h"}})
+		if code != http.StatusCreated {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusCreated, code)
+		}
+		if deck.ExpiresAt == nil || !deck.ExpiresAt.Equal(deck.CreatedAt.Add(time.Hour)) {
+			t.Errorf("Deck should expire an hour after it is created, created: %v, expires: %v", deck.CreatedAt, deck.ExpiresAt)
+		}
+		for _, ttl := range []string{"-1h", "soon"} {
+			if _, code = createTestDeck(t, router, url.Values{"ttl": {ttl}}); code != http.StatusBadRequest {
+				t.Errorf("HTTP status code is incorrect for %v. expected: %v, actual: %v", ttl, http.StatusBadRequest, code)
+			}
+		}
+	})
+	t.Run("Create deck with metadata", func(t *testing.T) {
+		//arrange
+		created, code := createTestDeck(t, router, url.Values{"owner": {"dealer-3"}, "labels": {"table_id=7,game_id=g-42"}})
+		if code != http.StatusCreated {
+			t.Fatalf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusCreated, code)
+		}
+
+		//act
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/cards/count/1", created.DeckId), nil)
+		router.ServeHTTP(w, req)
+		opened := openTestDeck(t, dealerRouter, created.DeckId)
+
+		//assert
+		if opened.Owner != "dealer-3" || opened.Labels["table_id"] != "7" || opened.Labels["game_id"] != "g-42" {
+			t.Errorf("Owner and labels should be kept, owner: %v, labels: %v", opened.Owner, opened.Labels)
+		}
+		if !opened.CreatedAt.Equal(created.CreatedAt) || created.CreatedAt.IsZero() {
+			t.Errorf("Creation time should be kept, expected: %v, actual: %v", created.CreatedAt, opened.CreatedAt)
+		}
+		if opened.LastDrawnAt == nil || opened.UpdatedAt.Before(created.UpdatedAt) || opened.LastDrawnAt.After(opened.UpdatedAt) {
+			t.Errorf("Drawing should be stamped, created: %v, updated: %v, last drawn: %v", opened.CreatedAt, opened.UpdatedAt, opened.LastDrawnAt)
+		}
+		for _, labels := range []string{"table_id", "bad key=1"} {
+			if _, code = createTestDeck(t, router, url.Values{"labels": {labels}}); code != http.StatusBadRequest {
+				t.Errorf("HTTP status code is incorrect for %v. expected: %v, actual: %v", labels, http.StatusBadRequest, code)
+			}
 		}
 	})
 	t.Run("Open deck", func(t *testing.T) {
@@ -162,8 +800,9 @@ func TestRouter(t *testing.T) {
 		//act
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s", seedBody.DeckId), nil)
+		req.Header.Set("Authorization", "Bearer "+testDealerKey)
 
-		router.ServeHTTP(w, req)
+		dealerRouter.ServeHTTP(w, req)
 
 		var resBody Deck
 		if resBodyBytes := w.Body.Bytes(); resBodyBytes != nil {
@@ -220,8 +859,9 @@ func TestRouter(t *testing.T) {
 		//act
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s", seedBody.DeckId), nil)
+		req.Header.Set("Authorization", "Bearer "+testDealerKey)
 
-		router.ServeHTTP(w, req)
+		dealerRouter.ServeHTTP(w, req)
 
 		var resBody Deck
 		if resBodyBytes := w.Body.Bytes(); resBodyBytes != nil {
@@ -273,8 +913,9 @@ func TestRouter(t *testing.T) {
 		//act
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s", seedBody.DeckId), nil)
+		req.Header.Set("Authorization", "Bearer "+testDealerKey)
 
-		router.ServeHTTP(w, req)
+		dealerRouter.ServeHTTP(w, req)
 
 		var resBody Deck
 		if resBodyBytes := w.Body.Bytes(); resBodyBytes != nil {
@@ -338,8 +979,8 @@ func TestRouter(t *testing.T) {
 
 		router.ServeHTTP(w, req)
 
-		if w.Code != http.StatusNotFound {
-			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusNotFound, w.Code)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusUnprocessableEntity, w.Code)
 		}
 	})
 	t.Run("Draw 1 card", func(t *testing.T) {
@@ -429,8 +1070,8 @@ func TestRouter(t *testing.T) {
 
 		router.ServeHTTP(w, req)
 
-		if w.Code != http.StatusNotFound {
-			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusNotFound, w.Code)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusUnprocessableEntity, w.Code)
 		}
 	})
 	t.Run("Draw more cards than a deck has", func(t *testing.T) {
@@ -452,10 +1093,766 @@ func TestRouter(t *testing.T) {
 
 		router.ServeHTTP(w, req)
 
+		if w.Code != http.StatusConflict {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusConflict, w.Code)
+		}
+
+	})
+	t.Run("Draw from positions", func(t *testing.T) {
+		tests := []struct {
+			// before is drawn first, on the same deck
+			before   string
+			query    string
+			expected int
+		}{
+			{"", "count/2?position=bottom", http.StatusOK},
+			{"", "count/2?position=random", http.StatusOK},
+			{"", "count/1?position=at&index=3", http.StatusOK},
+			{"", "count/1?position=at&index=52", http.StatusConflict},
+			{"", "count/1?position=at&index=9223372036854775807", http.StatusConflict},
+			{"", "count/2?codes=as,KH", http.StatusOK},
+			{"count/2?codes=AS,KH", "count/2?codes=as,KH", http.StatusConflict},
+			{"", "count/2?codes=QH,X1", http.StatusNotFound},
+			{"", "count/1?codes=QH,JH", http.StatusUnprocessableEntity},
+			{"", "count/1?codes=ZZ", http.StatusUnprocessableEntity},
+			{"", "count/1?position=middle", http.StatusBadRequest},
+			{"", "count/1?position=at&index=-1", http.StatusBadRequest},
+			{"", "count/2?codes=QH,JH", http.StatusOK},
+		}
+		for _, tt := range tests {
+			t.Run(tt.query, func(t *testing.T) {
+				//arrange
+				deck, _ := createTestDeck(t, router, url.Values{})
+				if tt.before != "" {
+					w := httptest.NewRecorder()
+					req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/cards/%s", deck.DeckId, tt.before), nil)
+					router.ServeHTTP(w, req)
+				}
+
+				//act
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/cards/%s", deck.DeckId, tt.query), nil)
+
+				router.ServeHTTP(w, req)
+
+				//assert
+				if w.Code != tt.expected {
+					t.Errorf("HTTP status code is incorrect for %v. expected: %v, actual: %v", tt.query, tt.expected, w.Code)
+				}
+			})
+		}
+	})
+	t.Run("Draw with a JSON body", func(t *testing.T) {
+		deck, _ := createTestDeck(t, router, url.Values{})
+		tests := []struct {
+			body     string
+			expected int
+		}{
+			{`{"count": 2}`, http.StatusOK},
+			{`{"count": 2, "position": "bottom"}`, http.StatusOK},
+			{`{"count": 1, "position": "at", "index": 3}`, http.StatusOK},
+			{`{"codes": ["JH", "th"]}`, http.StatusOK},
+			{`{"codes": ["QH"]}`, http.StatusConflict},
+			{`{"codes": ["X1"]}`, http.StatusNotFound},
+			{`{"codes": ["ZZ"]}`, http.StatusUnprocessableEntity},
+			{`{"count": 0}`, http.StatusUnprocessableEntity},
+			{`{"count": 1, "pile": "not a pile"}`, http.StatusBadRequest},
+			{`{"count": 100}`, http.StatusConflict},
+			{`count=1`, http.StatusBadRequest},
+			{``, http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/decks/%s/draw", deck.DeckId), strings.NewReader(tt.body))
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("HTTP status code is incorrect for %v. expected: %v, actual: %v", tt.body, tt.expected, w.Code)
+			}
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/decks/%s/draw", uuid.NewString()), strings.NewReader(`{"count": 1}`))
+		router.ServeHTTP(w, req)
 		if w.Code != http.StatusNotFound {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusNotFound, w.Code)
+		}
+	})
+	t.Run("Draw into a pile", func(t *testing.T) {
+		//arrange
+		deck, _ := createTestDeck(t, router, url.Values{})
+
+		//act
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/decks/%s/draw", deck.DeckId),
+			strings.NewReader(`{"count": 2, "position": "bottom", "pile": "burn"}`))
+		router.ServeHTTP(w, req)
+
+		//assert
+		if w.Code != http.StatusOK {
 			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusOK, w.Code)
 		}
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/piles/burn", deck.DeckId), nil)
+		req.Header.Set("Authorization", "Bearer "+testDealerKey)
+		dealerRouter.ServeHTTP(w, req)
+		var pile struct {
+			Cards []Card `json:"cards"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &pile); err != nil {
+			t.Error("Error while unmarshaling response body.")
+		}
+		if len(pile.Cards) != 2 || pile.Cards[0].Code != "AS" || pile.Cards[1].Code != "2S" {
+			t.Errorf("Drawn cards should be on the pile, actual: %v", pile.Cards)
+		}
+		if remaining := openTestDeck(t, dealerRouter, deck.DeckId).Remaining; remaining != 50 {
+			t.Errorf("Remaining is incorrect. expected: %v, actual: %v", 50, remaining)
+		}
+	})
+	t.Run("Legacy draw route is deprecated", func(t *testing.T) {
+		deck, _ := createTestDeck(t, router, url.Values{})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/cards/count/1", deck.DeckId), nil)
+
+		router.ServeHTTP(w, req)
+
+		if w.Header().Get("Deprecation") == "" {
+			t.Error("Deprecation header should be set")
+		}
+		if link := w.Header().Get("Link"); !strings.Contains(link, fmt.Sprintf("/decks/%s/draw", deck.DeckId)) {
+			t.Errorf("Link header should point to the draw endpoint, actual: %v", link)
+		}
+	})
+	t.Run("Retried draw with an idempotency key", func(t *testing.T) {
+		//arrange
+		deck, _ := createTestDeck(t, router, url.Values{"shuffle": {"true"}})
+		draw := func(key string, body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/decks/%s/draw", deck.DeckId), strings.NewReader(body))
+			req.Header.Set("Idempotency-Key", key)
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		//act
+		first := draw("draw-1", `{"count": 2}`)
+		retry := draw("draw-1", `{"count": 2}`)
+		reused := draw("draw-1", `{"count": 3}`)
+
+		//assert
+		if first.Code != http.StatusOK || retry.Code != http.StatusOK {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v and %v", http.StatusOK, first.Code, retry.Code)
+		}
+		if first.Body.String() != retry.Body.String() || retry.Header().Get("Idempotent-Replayed") != "true" {
+			t.Errorf("Retry should replay the first response, first: %v, retry: %v", first.Body, retry.Body)
+		}
+		if reused.Code != http.StatusUnprocessableEntity {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusUnprocessableEntity, reused.Code)
+		}
+		if remaining := openTestDeck(t, dealerRouter, deck.DeckId).Remaining; remaining != 50 {
+			t.Errorf("Cards should be drawn once. expected: %v, actual: %v", 50, remaining)
+		}
+
+		other, _ := createTestDeck(t, router, url.Values{})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/decks/%s/draw", other.DeckId), strings.NewReader(`{"count": 2}`))
+		req.Header.Set("Idempotency-Key", "draw-1")
+		router.ServeHTTP(w, req)
+		if w.Header().Get("Idempotent-Replayed") != "" || openTestDeck(t, dealerRouter, other.DeckId).Remaining != 50 {
+			t.Error("Idempotency keys should be scoped to the deck")
+		}
+	})
+	t.Run("Retried deck creation with an idempotency key", func(t *testing.T) {
+		var ids []string
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/decks?shuffle=true", nil)
+			req.Header.Set("Idempotency-Key", "create-1")
+			router.ServeHTTP(w, req)
+			var deck Deck
+			if err := json.Unmarshal(w.Body.Bytes(), &deck); err != nil || w.Code != http.StatusCreated {
+				t.Fatalf("Failed to create deck, status: %v, error: %v", w.Code, err)
+			}
+			ids = append(ids, deck.DeckId)
+		}
+		if ids[0] != ids[1] {
+			t.Errorf("Retry should return the same deck, expected: %v, actual: %v", ids[0], ids[1])
+		}
+	})
+	t.Run("Idempotency window expires", func(t *testing.T) {
+		shortRouter := SetupRouter(store, WithIdempotencyWindow(time.Millisecond))
+		deck, _ := createTestDeck(t, router, url.Values{})
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/decks/%s/draw", deck.DeckId), strings.NewReader(`{"count": 1}`))
+			req.Header.Set("Idempotency-Key", "short")
+			shortRouter.ServeHTTP(w, req)
+			time.Sleep(5 * time.Millisecond)
+		}
+		if remaining := openTestDeck(t, dealerRouter, deck.DeckId).Remaining; remaining != 50 {
+			t.Errorf("A retry after the window should draw again. expected: %v, actual: %v", 50, remaining)
+		}
+	})
+	t.Run("Peek requires a permitted API key", func(t *testing.T) {
+		//arrange
+		deck, _ := createTestDeck(t, router, url.Values{"shuffle": {"true"}})
+		tests := []struct {
+			handler  http.Handler
+			key      string
+			expected int
+		}{
+			{router, testDealerKey, http.StatusForbidden},
+			{dealerRouter, "", http.StatusUnauthorized},
+			{dealerRouter, "player-key", http.StatusForbidden},
+			{dealerRouter, testDealerKey, http.StatusOK},
+		}
+
+		//act
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/cards/peek/3", deck.DeckId), nil)
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+
+			tt.handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("HTTP status code is incorrect for key %q. expected: %v, actual: %v", tt.key, tt.expected, w.Code)
+			}
+		}
+	})
+	t.Run("Peek shows the next cards without drawing them", func(t *testing.T) {
+		//arrange
+		deck, _ := createTestDeck(t, router, url.Values{"shuffle": {"true"}})
+
+		//act
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/cards/peek/3", deck.DeckId), nil)
+		req.Header.Set("Authorization", "Bearer "+testDealerKey)
+		dealerRouter.ServeHTTP(w, req)
+		var peeked map[string][]Card
+		if err := json.Unmarshal(w.Body.Bytes(), &peeked); err != nil {
+			t.Error("Error while unmarshaling response body.")
+		}
+
+		//assert
+		if remaining := openTestDeck(t, dealerRouter, deck.DeckId).Remaining; remaining != 52 {
+			t.Errorf("Peek should not draw cards. expected: %v, actual: %v", 52, remaining)
+		}
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/cards/count/3", deck.DeckId), nil)
+		router.ServeHTTP(w, req)
+		var drawn map[string][]Card
+		if err := json.Unmarshal(w.Body.Bytes(), &drawn); err != nil {
+			t.Error("Error while unmarshaling response body.")
+		}
+		if !sameOrder(peeked["cards"], drawn["cards"]) {
+			t.Errorf("Peeked cards should be drawn next. peeked: %v, drawn: %v", peeked["cards"], drawn["cards"])
+		}
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/cards/peek/50", deck.DeckId), nil)
+		req.Header.Set("Authorization", "Bearer "+testDealerKey)
+		dealerRouter.ServeHTTP(w, req)
+		if w.Code != http.StatusConflict {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusConflict, w.Code)
+		}
+	})
+	t.Run("Open deck hides cards from callers who may not peek", func(t *testing.T) {
+		//arrange
+		deck, _ := createTestDeck(t, router, url.Values{"shuffle": {"true"}})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/decks/%s/piles/player1/cards?count=2", deck.DeckId), nil)
+		router.ServeHTTP(w, req)
+		tests := []struct {
+			handler http.Handler
+			key     string
+			visible bool
+		}{
+			{router, "", false},
+			{router, testDealerKey, false},
+			{dealerRouter, "player-key", false},
+			{dealerRouter, testDealerKey, true},
+		}
+
+		for _, tt := range tests {
+			//act
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s", deck.DeckId), nil)
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			tt.handler.ServeHTTP(w, req)
+			var opened Deck
+			if err := json.Unmarshal(w.Body.Bytes(), &opened); err != nil {
+				t.Error("Error while unmarshaling response body to Deck struct.")
+			}
+
+			//assert
+			if w.Code != http.StatusOK || opened.Remaining != 50 {
+				t.Errorf("Deck should open for key %q, status: %v, remaining: %v", tt.key, w.Code, opened.Remaining)
+			}
+			if visible := len(opened.Cards) == 50 && len(opened.Piles["player1"]) == 2; visible != tt.visible {
+				t.Errorf("Cards visibility is incorrect for key %q. expected: %v, actual: %v", tt.key, tt.visible, visible)
+			}
+			if !tt.visible && (len(opened.Cards) > 0 || len(opened.Piles) > 0) {
+				t.Errorf("Cards should be hidden for key %q, actual: %v, piles: %v", tt.key, opened.Cards, opened.Piles)
+			}
+		}
+	})
+	t.Run("Deck responses hide cards from callers who may not peek", func(t *testing.T) {
+		//arrange
+		deck, _ := createTestDeck(t, router, url.Values{})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/decks/%s/piles/player1/cards?count=2", deck.DeckId), nil)
+		router.ServeHTTP(w, req)
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/decks/%s/piles/burn/cards", deck.DeckId), nil)
+		router.ServeHTTP(w, req)
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/cards/count/1", deck.DeckId), nil)
+		router.ServeHTTP(w, req)
+		var drawn map[string][]Card
+		if err := json.Unmarshal(w.Body.Bytes(), &drawn); err != nil || len(drawn["cards"]) != 1 {
+			t.Fatalf("Drawing a card to return failed, status: %v", w.Code)
+		}
+		tests := []struct {
+			method string
+			path   string
+		}{
+			{http.MethodPost, "/cut?at=3"},
+			{http.MethodPost, "/shuffle"},
+			{http.MethodPost, "/cards/return?cards=" + drawn["cards"][0].Code},
+			{http.MethodPost, "/piles/player1/shuffle"},
+			{http.MethodPost, "/archive"},
+			{http.MethodPost, "/restore"},
+			{http.MethodPost, "/close"},
+		}
+
+		for _, tt := range tests {
+			//act
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, fmt.Sprintf("/decks/%s%s", deck.DeckId, tt.path), nil)
+			router.ServeHTTP(w, req)
+			var result map[string]json.RawMessage
+			json.Unmarshal(w.Body.Bytes(), &result)
+
+			//assert
+			if w.Code != http.StatusOK {
+				t.Errorf("HTTP status code is incorrect for %v %v. expected: %v, actual: %v", tt.method, tt.path, http.StatusOK, w.Code)
+			}
+			if _, ok := result["piles"]; ok {
+				t.Errorf("%v %v should hide piles, actual: %s", tt.method, tt.path, result["piles"])
+			}
+			if _, ok := result["cards"]; ok {
+				t.Errorf("%v %v should hide cards, actual: %s", tt.method, tt.path, result["cards"])
+			}
+		}
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/piles/player1", deck.DeckId), nil)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Listing a pile should need the peek permission, actual: %v", w.Code)
+		}
+		opened := openTestDeck(t, dealerRouter, deck.DeckId)
+		if len(opened.Piles["burn"]) != 1 {
+			t.Errorf("Callers who may peek should still see piles, actual: %v", opened.Piles)
+		}
+	})
+	t.Run("Errors share one envelope", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s", uuid.NewString()), nil)
+
+		router.ServeHTTP(w, req)
+
+		var resBody ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resBody); err != nil {
+			t.Error("Error while unmarshaling response body to ErrorResponse struct.")
+		}
+		if w.Code != http.StatusNotFound || resBody.Code != "deck_not_found" || resBody.Message != ErrDeckNotFound.Error() {
+			t.Errorf("Error response is incorrect, status: %v, body: %v", w.Code, resBody)
+		}
+	})
+	t.Run("Evaluate a poker hand", func(t *testing.T) {
+		//arrange
+		body := `{"cards": ["10s", "JS", "2D", "QS", "as", "3C", "KS"]}`
+
+		//act
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/hands/evaluate", strings.NewReader(body))
+		router.ServeHTTP(w, req)
+
+		//assert
+		var resBody struct {
+			Category string `json:"category"`
+			Rank     int    `json:"rank"`
+			Best     []Card `json:"best"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resBody); err != nil {
+			t.Error("Error while unmarshaling response body.")
+		}
+		expected, _ := ParseCards("AS,KS,QS,JS,TS")
+		if w.Code != http.StatusOK || resBody.Category != "royal_flush" || resBody.Rank != 7462 || !sameOrder(resBody.Best, expected) {
+			t.Errorf("Hand evaluation is incorrect, status: %v, body: %v", w.Code, resBody)
+		}
+	})
+	t.Run("Evaluate an invalid poker hand", func(t *testing.T) {
+		tests := []struct {
+			body string
+			code string
+		}{
+			{`{"cards": ["AS", "KS", "QS", "JS"]}`, "invalid_count"},
+			{`{"cards": ["AS", "KS", "QS", "JS", "TS", "9S", "8S", "7S"]}`, "invalid_count"},
+			{`{"cards": ["AS", "KS", "QS", "JS", "X1"]}`, "invalid_card_code"},
+			{`{"cards": ["AS", "KS", "QS", "JS", "as"]}`, "invalid_card_code"},
+		}
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/hands/evaluate", strings.NewReader(tt.body))
+			router.ServeHTTP(w, req)
+
+			var resBody ErrorResponse
+			json.Unmarshal(w.Body.Bytes(), &resBody)
+			if w.Code != http.StatusUnprocessableEntity || resBody.Code != tt.code {
+				t.Errorf("Evaluating %s should fail with %v, status: %v, body: %v", tt.body, tt.code, w.Code, resBody)
+			}
+		}
+	})
+	t.Run("Showdown between players", func(t *testing.T) {
+		//arrange
+		body := `{
+			"board": ["AS", "AD", "9C", "6H", "2S"],
+			"players": [
+				{"id": "alice", "cards": ["KC", "4D"]},
+				{"id": "bob", "cards": ["QC", "4H"]},
+				{"id": "carol", "cards": ["KH", "3D"]}
+			]
+		}`
+
+		//act
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/hands/showdown", strings.NewReader(body))
+		router.ServeHTTP(w, req)
+
+		//assert
+		var resBody Showdown
+		if err := json.Unmarshal(w.Body.Bytes(), &resBody); err != nil {
+			t.Error("Error while unmarshaling response body to Showdown struct.")
+		}
+		if w.Code != http.StatusOK || !resBody.Split || strings.Join(resBody.Winners, ",") != "alice,carol" {
+			t.Errorf("Showdown winners are incorrect, status: %v, body: %v", w.Code, resBody)
+		}
+		expected := []string{
+			"alice and carol split the pot with pair of aces, kickers king, nine, six",
+			"alice beats bob: pair of aces, kickers king, nine, six beats pair of aces, kickers queen, nine, six on the first kicker, king over queen",
+		}
+		if strings.Join(resBody.Reasons, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("Showdown reasons are incorrect, expected: %v, actual: %v", expected, resBody.Reasons)
+		}
+		if len(resBody.Hands) != 3 || resBody.Hands[2].Player != "bob" || resBody.Hands[2].Category != hand.OnePair {
+			t.Errorf("Showdown hands are incorrect, actual: %v", resBody.Hands)
+		}
+	})
+	t.Run("Showdown with invalid hands", func(t *testing.T) {
+		tests := []struct {
+			body   string
+			status int
+		}{
+			{`{"board": ["AS", "AD", "9C", "6H", "2S"], "players": [{"id": "alice", "cards": ["KC", "4D"]}]}`, http.StatusBadRequest},
+			{`{"board": ["AS", "AD", "9C", "6H", "2S"], "players": [{"id": "alice", "cards": ["KC", "4D"]}, {"id": "alice", "cards": ["QC", "4H"]}]}`, http.StatusBadRequest},
+			{`{"board": ["AS", "AD", "9C", "6H", "2S"], "players": [{"id": "alice", "cards": ["KC", "4D"]}, {"id": "bob", "cards": ["KC", "4H"]}]}`, http.StatusUnprocessableEntity},
+			{`{"board": ["AS", "AD", "9C", "6H", "2S"], "players": [{"id": "alice", "cards": ["KC", "4D"]}, {"id": "bob", "cards": ["QC", "4H", "3H"]}]}`, http.StatusUnprocessableEntity},
+			{`{"board": ["AS", "AD", "9C", "6H", "2S"], "players": [{"id": "alice", "cards": ["KC", "4D"]}, {"id": "bob", "cards": []}]}`, http.StatusUnprocessableEntity},
+			{`{"board": ["AS", "AD"], "players": [{"id": "alice", "cards": ["KC", "4D"]}, {"id": "bob", "cards": ["QC", "4H"]}]}`, http.StatusUnprocessableEntity},
+		}
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/hands/showdown", strings.NewReader(tt.body))
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Showdown %s should fail with %v, actual: %v", tt.body, tt.status, w.Code)
+			}
+		}
+	})
+	t.Run("Equity of a Hold'em spot", func(t *testing.T) {
+		//arrange
+		body := `{
+			"board": ["2S", "7S", "QC"],
+			"players": [
+				{"id": "hero", "cards": ["AS", "KS"]},
+				{"id": "villain", "range": "QhQd,7h7d"}
+			],
+			"budget": "500ms"
+		}`
+
+		//act
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/hands/equity", strings.NewReader(body))
+		router.ServeHTTP(w, req)
+
+		//assert
+		var resBody EquityResult
+		if err := json.Unmarshal(w.Body.Bytes(), &resBody); err != nil {
+			t.Error("Error while unmarshaling response body to EquityResult struct.")
+		}
+		if w.Code != http.StatusOK || resBody.Method != EquityExact || resBody.Trials != 2*990 || len(resBody.Players) != 2 {
+			t.Errorf("Equity response is incorrect, status: %v, body: %v", w.Code, resBody)
+		}
+		if hero := resBody.Players[0]; hero.Player != "hero" || hero.Equity <= 0 || hero.Equity >= 50 {
+			t.Errorf("Hero equity against a set is incorrect, actual: %v", hero)
+		}
+	})
+	t.Run("Equity with an invalid budget", func(t *testing.T) {
+		body := `{"players": [{"id": "hero", "cards": ["AS", "KS"]}, {"id": "villain", "cards": ["QH", "QD"]}], "budget": "soon"}`
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/hands/equity", strings.NewReader(body))
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("HTTP status code is incorrect. expected: %v, actual: %v", http.StatusBadRequest, w.Code)
+		}
+	})
+	t.Run("Expand a hand range", func(t *testing.T) {
+		//arrange
+		body := `{"range": "QQ+, AKs:0.5", "dead": ["as", "KD"]}`
+
+		//act
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/hands/range", strings.NewReader(body))
+		router.ServeHTTP(w, req)
+
+		//assert
+		var resBody RangeResult
+		if err := json.Unmarshal(w.Body.Bytes(), &resBody); err != nil {
+			t.Error("Error while unmarshaling response body to RangeResult struct.")
+		}
+		// aces and kings lose 3 combos each, AKs loses spades and diamonds
+		if w.Code != http.StatusOK || resBody.Count != 14 || resBody.Blocked != 8 || resBody.Weighted != 13 || len(resBody.Combos) != 14 {
+			t.Errorf("Range response is incorrect, status: %v, body: %v", w.Code, resBody)
+		}
+		for _, combo := range resBody.Combos {
+			for _, card := range combo.Cards {
+				if card.Code == "AS" || card.Code == "KD" {
+					t.Errorf("Combo %v holds a dead card", combo.Cards)
+				}
+			}
+		}
+	})
+	t.Run("Expand an invalid hand range", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/hands/range", strings.NewReader(`{"range": "QQs"}`))
+		router.ServeHTTP(w, req)
+
+		var resBody ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resBody)
+		if w.Code != http.StatusUnprocessableEntity || resBody.Code != "invalid_range" {
+			t.Errorf("Error response is incorrect, status: %v, body: %v", w.Code, resBody)
+		}
+	})
+	t.Run("Deal a Hold'em hand at a table", func(t *testing.T) {
+		//arrange
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/tables", strings.NewReader(`{"seats": 6, "players": ["alice", "bob"]}`))
+		router.ServeHTTP(w, req)
+		var table Table
+		if err := json.Unmarshal(w.Body.Bytes(), &table); err != nil {
+			t.Error("Error while unmarshaling response body to Table struct.")
+		}
+		if w.Code != http.StatusCreated || len(table.Seats) != 6 || table.Phase != PhaseWaiting {
+			t.Fatalf("Table was not created, status: %v, body: %v", w.Code, table)
+		}
 
+		//act
+		var phases []string
+		for i := 0; i < 5; i++ {
+			w = httptest.NewRecorder()
+			req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/tables/%s/advance", table.TableId), nil)
+			router.ServeHTTP(w, req)
+			if err := json.Unmarshal(w.Body.Bytes(), &table); err != nil {
+				t.Error("Error while unmarshaling response body to Table struct.")
+			}
+			phases = append(phases, table.Phase)
+		}
+
+		//assert
+		if expected := "preflop,flop,turn,river,showdown"; strings.Join(phases, ",") != expected {
+			t.Errorf("Phases are incorrect, expected: %v, actual: %v", expected, phases)
+		}
+		if len(table.Board) != 5 || len(table.Seats[0].Cards) != 2 || len(table.Seats[1].Cards) != 2 || table.Showdown == nil {
+			t.Errorf("Hand was not dealt, actual: %v", table)
+		}
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s/reveal", table.DeckId), nil)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("Deck of a finished hand should be revealable, actual: %v", w.Code)
+		}
+	})
+	t.Run("Table keeps hole cards and its deck to itself", func(t *testing.T) {
+		//arrange
+		table, _ := CreateTable(store, TableOptions{Players: []string{"alice", "bob"}})
+		table, _ = AdvanceTable(store, table.TableId)
+
+		//act
+		var seen []Table
+		for _, handler := range []http.Handler{router, dealerRouter} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/tables/%s", table.TableId), nil)
+			req.Header.Set("Authorization", "Bearer "+testDealerKey)
+			handler.ServeHTTP(w, req)
+			var opened Table
+			if err := json.Unmarshal(w.Body.Bytes(), &opened); err != nil {
+				t.Error("Error while unmarshaling response body to Table struct.")
+			}
+			seen = append(seen, opened)
+		}
+
+		//assert
+		if len(seen[0].Seats[0].Cards) != 0 || len(seen[0].Seats[1].Cards) != 0 {
+			t.Errorf("Hole cards should be hidden before the showdown, actual: %v", seen[0].Seats)
+		}
+		if len(seen[1].Seats[0].Cards) != 2 || len(seen[1].Seats[1].Cards) != 2 {
+			t.Errorf("Hole cards should show to callers who may peek, actual: %v", seen[1].Seats)
+		}
+		tests := []struct {
+			method string
+			path   string
+		}{
+			{http.MethodGet, "/cards/count/1"},
+			{http.MethodPost, "/draw"},
+			{http.MethodPost, "/shuffle"},
+			{http.MethodPost, "/cut?at=3"},
+			{http.MethodGet, "/piles/seat-1"},
+			{http.MethodPost, "/close"},
+			{http.MethodDelete, ""},
+		}
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, fmt.Sprintf("/decks/%s%s", table.DeckId, tt.path), strings.NewReader(`{"count": 1}`))
+			req.Header.Set("Authorization", "Bearer "+testDealerKey)
+			dealerRouter.ServeHTTP(w, req)
+
+			var resBody ErrorResponse
+			json.Unmarshal(w.Body.Bytes(), &resBody)
+			if w.Code != http.StatusConflict || resBody.Code != "table_deck" {
+				t.Errorf("%v %v on a table's deck should answer 409 table_deck, actual: %v %v", tt.method, tt.path, w.Code, resBody.Code)
+			}
+		}
+		if advanced, err := AdvanceTable(store, table.TableId); err != nil || advanced.Phase != PhaseFlop {
+			t.Errorf("Table should still deal from its deck, phase: %v, error: %v", advanced.Phase, err)
+		}
+	})
+	t.Run("Seated player sees their own hole cards", func(t *testing.T) {
+		//arrange
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/tables", strings.NewReader(`{"players": ["alice", "bob"]}`))
+		router.ServeHTTP(w, req)
+		var table Table
+		if err := json.Unmarshal(w.Body.Bytes(), &table); err != nil || table.Seats[0].Token == "" {
+			t.Fatalf("Creating a table should hand out seat tokens, status: %v, error: %v", w.Code, err)
+		}
+		token := table.Seats[0].Token
+		table, _ = AdvanceTable(store, table.TableId)
+		tests := []struct {
+			token    string
+			expected int
+			code     string
+		}{
+			{"", http.StatusUnauthorized, "seat_token_required"},
+			{"guess", http.StatusForbidden, "seat_token_invalid"},
+			{testDealerKey, http.StatusForbidden, "seat_token_invalid"},
+		}
+
+		//act
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/tables/%s/seats/1/cards", table.TableId), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+		var seat Seat
+		json.Unmarshal(w.Body.Bytes(), &seat)
+
+		//assert
+		if w.Code != http.StatusOK || seat.Player != "alice" || len(seat.Cards) != 2 {
+			t.Errorf("Seat token should show the player's hole cards, status: %v, seat: %+v", w.Code, seat)
+		}
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/tables/%s/seats/1/cards", table.TableId), nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			dealerRouter.ServeHTTP(w, req)
+			var resBody ErrorResponse
+			json.Unmarshal(w.Body.Bytes(), &resBody)
+			if w.Code != tt.expected || resBody.Code != tt.code {
+				t.Errorf("Response is incorrect for token %q. expected: %v %v, actual: %v %v", tt.token, tt.expected, tt.code, w.Code, resBody.Code)
+			}
+		}
+	})
+	t.Run("Retried advance replays only to the same caller", func(t *testing.T) {
+		//arrange
+		table, _ := CreateTable(store, TableOptions{Players: []string{"alice", "bob"}})
+		advance := func(handler http.Handler, key string) (*httptest.ResponseRecorder, Table) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/tables/%s/advance", table.TableId), nil)
+			req.Header.Set("Idempotency-Key", "deal-1")
+			if key != "" {
+				req.Header.Set("Authorization", "Bearer "+key)
+			}
+			handler.ServeHTTP(w, req)
+			var advanced Table
+			if err := json.Unmarshal(w.Body.Bytes(), &advanced); err != nil {
+				t.Error("Error while unmarshaling response body to Table struct.")
+			}
+			return w, advanced
+		}
+
+		//act
+		_, dealt := advance(dealerRouter, testDealerKey)
+		retry, _ := advance(dealerRouter, testDealerKey)
+		anonymous, seen := advance(dealerRouter, "")
+
+		//assert
+		if len(dealt.Seats[0].Cards) != 2 || retry.Header().Get("Idempotent-Replayed") != "true" {
+			t.Errorf("The dealer's retry should replay the dealt hand, actual: %v", dealt.Seats)
+		}
+		if anonymous.Header().Get("Idempotent-Replayed") != "" || len(seen.Seats[0].Cards) != 0 {
+			t.Errorf("The dealer's response should not be replayed to anyone else, actual: %v", seen.Seats)
+		}
+	})
+	t.Run("Table errors", func(t *testing.T) {
+		table, _ := CreateTable(store, TableOptions{Seats: 3, Players: []string{"alice"}})
+		tests := []struct {
+			method string
+			path   string
+			body   string
+			status int
+			code   string
+		}{
+			{http.MethodPost, "/tables", `{"players": ["alice"]}`, http.StatusBadRequest, "bad_request"},
+			{http.MethodPost, "/tables", `{"players": ["alice", "alice"]}`, http.StatusBadRequest, "bad_request"},
+			{http.MethodGet, fmt.Sprintf("/tables/%s", uuid.NewString()), "", http.StatusNotFound, "table_not_found"},
+			{http.MethodPost, fmt.Sprintf("/tables/%s/advance", table.TableId), "", http.StatusConflict, "not_enough_players"},
+			{http.MethodPut, fmt.Sprintf("/tables/%s/seats/1", table.TableId), `{"player": "bob"}`, http.StatusConflict, "seat_taken"},
+			{http.MethodPut, fmt.Sprintf("/tables/%s/seats/4", table.TableId), `{"player": "bob"}`, http.StatusBadRequest, "bad_request"},
+			{http.MethodPut, fmt.Sprintf("/tables/%s/seats/2", table.TableId), `{"player": "bob"}`, http.StatusOK, ""},
+			{http.MethodPost, fmt.Sprintf("/tables/%s/advance", table.TableId), "", http.StatusOK, ""},
+			{http.MethodDelete, fmt.Sprintf("/tables/%s/seats/2", table.TableId), "", http.StatusConflict, "hand_in_progress"},
+		}
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			router.ServeHTTP(w, req)
+
+			var resBody ErrorResponse
+			json.Unmarshal(w.Body.Bytes(), &resBody)
+			if w.Code != tt.status || resBody.Code != tt.code {
+				t.Errorf("%v %v should answer %v %v, actual: %v %v", tt.method, tt.path, tt.status, tt.code, w.Code, resBody.Code)
+			}
+		}
 	})
 	t.Run("Draw cards from invalid deck", func(t *testing.T) {
 		//arrange
@@ -481,7 +1878,47 @@ func TestRouter(t *testing.T) {
 		}
 
 	})
-	TeardownDb()
+	TeardownDb(store)
+}
+
+// testDealerKey is the API key that may peek on the dealer router of TestRouter.
+const testDealerKey = "dealer-key"
+
+func createTestDeck(t *testing.T, router http.Handler, query url.Values) (Deck, int) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/decks?"+query.Encode(), nil)
+	router.ServeHTTP(w, req)
+	var deck Deck
+	if w.Code == http.StatusCreated {
+		if err := json.Unmarshal(w.Body.Bytes(), &deck); err != nil {
+			t.Error("Error while unmarshaling response body to Deck struct.")
+		}
+	}
+	return deck, w.Code
+}
+
+func openTestDeck(t *testing.T, router http.Handler, deckId string) Deck {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/decks/%s", deckId), nil)
+	req.Header.Set("Authorization", "Bearer "+testDealerKey)
+	router.ServeHTTP(w, req)
+	var deck Deck
+	if err := json.Unmarshal(w.Body.Bytes(), &deck); err != nil {
+		t.Error("Error while unmarshaling response body to Deck struct.")
+	}
+	return deck
+}
+
+func sameOrder(a []Card, b []Card) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
 
 func isShuffled(cards []Card) bool {
@@ -491,16 +1928,7 @@ func isShuffled(cards []Card) bool {
 
 	for _, suit := range allSuits {
 		for _, value := range allValues {
-			firstCharValueRune := []rune(value)
-			firstCharValue := string(firstCharValueRune[0:1])
-			firstCharSuitRune := []rune(suit)
-			firstCharSuit := string(firstCharSuitRune[0:1])
-			cardCode := firstCharValue + firstCharSuit
-			sequentialCards = append(sequentialCards, Card{
-				Value: value,
-				Suit:  suit,
-				Code:  cardCode,
-			})
+			sequentialCards = append(sequentialCards, NewCard(value, suit))
 		}
 	}
 
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps decks in process memory. It is safe for concurrent use.
+type MemoryStore struct {
+	mu        sync.Mutex
+	decks     map[string]Deck
+	responses map[string]StoredResponse
+	tables    map[string]Table
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// sweepInterval is how often the memory store drops expired decks and
+// responses, the way a TTL index does in Mongo.
+const sweepInterval = time.Minute
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{
+		decks:     make(map[string]Deck),
+		responses: make(map[string]StoredResponse),
+		tables:    make(map[string]Table),
+		done:      make(chan struct{}),
+	}
+	go s.sweepEvery(sweepInterval)
+	return s
+}
+
+func (s *MemoryStore) sweepEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.sweep(now)
+		}
+	}
+}
+
+// sweep removes the decks and responses that expired by now.
+func (s *MemoryStore) sweep(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, deck := range s.decks {
+		if deck.expired(now) {
+			delete(s.decks, id)
+		}
+	}
+	for id, response := range s.responses {
+		if !now.Before(response.ExpiresAt) {
+			delete(s.responses, id)
+		}
+	}
+}
+
+func (s *MemoryStore) InsertDeck(deck Deck) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.decks[deck.DeckId]; exists {
+		return nil, errors.New("deck already exists")
+	}
+	s.decks[deck.DeckId] = deck.clone()
+	return deck.DeckId, nil
+}
+
+func (s *MemoryStore) GetDeck(deckId string) (Deck, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deck, exists := s.decks[deckId]
+	if !exists || deck.expired(time.Now()) {
+		return Deck{}, ErrDeckNotFound
+	}
+	return deck.clone(), nil
+}
+
+func (s *MemoryStore) UpdateDeck(deckId string, update func(deck *Deck) error) (Deck, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deck, exists := s.decks[deckId]
+	if !exists || deck.expired(time.Now()) {
+		return Deck{}, ErrDeckNotFound
+	}
+	deck = deck.clone()
+	if err := applyUpdate(&deck, update); err != nil {
+		return Deck{}, err
+	}
+	deck.Version++
+	s.decks[deckId] = deck
+	return deck.clone(), nil
+}
+
+func (s *MemoryStore) DrawCardsFromDeck(deckId string, count int) ([]Card, error) {
+	var cards []Card
+	_, err := s.UpdateDeck(deckId, func(deck *Deck) error {
+		var err error
+		cards, err = deck.PopCards(count)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cards, nil
+}
+
+func (s *MemoryStore) ListDecks(filter DeckFilter, after *DeckCursor, limit int) ([]Deck, error) {
+	s.mu.Lock()
+	now := time.Now()
+	var decks []Deck
+	for _, deck := range s.decks {
+		if !deck.expired(now) && filter.matches(deck) && (after == nil || after.precedes(deck)) {
+			deck.Cards, deck.Drawn, deck.Piles = nil, nil, nil
+			decks = append(decks, deck)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(decks, func(i, j int) bool {
+		return DeckCursor{CreatedAt: decks[i].CreatedAt, DeckId: decks[i].DeckId}.precedes(decks[j])
+	})
+	if len(decks) > limit {
+		decks = decks[:limit]
+	}
+	return decks, nil
+}
+
+func (s *MemoryStore) DeleteDeck(deckId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deck, exists := s.decks[deckId]
+	if !exists || deck.expired(time.Now()) {
+		return ErrDeckNotFound
+	}
+	delete(s.decks, deckId)
+	return nil
+}
+
+func (s *MemoryStore) ReserveResponse(response StoredResponse) (StoredResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored, exists := s.responses[response.Id]; exists && time.Now().Before(stored.ExpiresAt) {
+		return stored, false, nil
+	}
+	s.responses[response.Id] = response
+	return response, true, nil
+}
+
+func (s *MemoryStore) SaveResponse(response StoredResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[response.Id] = response
+	return nil
+}
+
+func (s *MemoryStore) DeleteResponse(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.responses, id)
+	return nil
+}
+
+func (s *MemoryStore) InsertTable(table Table) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.tables[table.TableId]; exists {
+		return errors.New("table already exists")
+	}
+	s.tables[table.TableId] = table.clone()
+	return nil
+}
+
+func (s *MemoryStore) GetTable(tableId string) (Table, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	table, exists := s.tables[tableId]
+	if !exists {
+		return Table{}, ErrTableNotFound
+	}
+	return table.clone(), nil
+}
+
+func (s *MemoryStore) UpdateTable(tableId string, update func(table *Table) error) (Table, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	table, exists := s.tables[tableId]
+	if !exists {
+		return Table{}, ErrTableNotFound
+	}
+	table = table.clone()
+	if err := applyTableUpdate(&table, update); err != nil {
+		return Table{}, err
+	}
+	table.Version++
+	s.tables[tableId] = table
+	return table.clone(), nil
+}
+
+func (s *MemoryStore) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	return nil
+}
+
+// clone copies the slices and maps of a deck so callers never share them with
+// the store.
+func (d Deck) clone() Deck {
+	d.Cards = append([]Card(nil), d.Cards...)
+	d.Drawn = append([]Card(nil), d.Drawn...)
+	d.PastCommitments = append([]PastCommitment(nil), d.PastCommitments...)
+	if d.Labels != nil {
+		labels := make(map[string]string, len(d.Labels))
+		for key, value := range d.Labels {
+			labels[key] = value
+		}
+		d.Labels = labels
+	}
+	if d.Piles != nil {
+		piles := make(map[string][]Card, len(d.Piles))
+		for name, cards := range d.Piles {
+			piles[name] = append([]Card(nil), cards...)
+		}
+		d.Piles = piles
+	}
+	return d
+}
+
+// clone copies the seats, cards and showdown of a table so callers never
+// share them with the store.
+func (t Table) clone() Table {
+	seats := make([]Seat, len(t.Seats))
+	for i, seat := range t.Seats {
+		seat.Cards = append([]Card(nil), seat.Cards...)
+		seats[i] = seat
+	}
+	t.Seats = seats
+	t.Board = append([]Card(nil), t.Board...)
+	if t.Showdown != nil {
+		showdown := *t.Showdown
+		showdown.Winners = append([]string(nil), showdown.Winners...)
+		showdown.Hands = append([]PlayerHand(nil), showdown.Hands...)
+		showdown.Reasons = append([]string(nil), showdown.Reasons...)
+		t.Showdown = &showdown
+	}
+	return t
+}
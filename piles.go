@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var ErrPileNotFound = errors.New("pile not found")
+
+// Pile names become document keys in the Mongo store, so they are kept to a
+// safe alphabet.
+var pileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+func ValidatePileName(name string) error {
+	if !pileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid pile name %q, use up to 32 letters, digits, '-' or '_'", name)
+	}
+	return nil
+}
+
+// popTop removes count cards from the top (the end) of cards and returns what
+// is left and the removed cards, topmost first.
+func popTop(cards []Card, count int) ([]Card, []Card, error) {
+	if count < 0 {
+		return cards, nil, fmt.Errorf("%w: must not be negative", ErrInvalidCount)
+	}
+	if len(cards) < count {
+		return cards, nil, ErrInsufficientCards
+	}
+	var removed []Card
+	for i := 0; i < count; i++ {
+		var removedCard Card
+		removedCard, cards = cards[len(cards)-1], cards[:len(cards)-1]
+		removed = append(removed, removedCard)
+	}
+	return cards, removed, nil
+}
+
+// takeCodes removes one card per requested code from cards, the copy nearest
+// the top first. If any code is missing nothing is removed and a
+// *CardCodeError lists the missing codes.
+func takeCodes(cards []Card, requested []Card) ([]Card, []Card, error) {
+	left := append([]Card(nil), cards...)
+	var taken []Card
+	codeErr := &CardCodeError{}
+	for _, card := range requested {
+		found := false
+		for i := len(left) - 1; i >= 0; i-- {
+			if left[i].Canonical().Code == card.Code {
+				taken = append(taken, left[i])
+				left = append(left[:i], left[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			codeErr.Missing = append(codeErr.Missing, card.Code)
+		}
+	}
+	if len(codeErr.Missing) > 0 {
+		return cards, nil, codeErr
+	}
+	return left, taken, nil
+}
+
+// source returns the cards of the named pile, the draw pile when name is empty.
+func (d *Deck) source(name string) ([]Card, error) {
+	if name == "" {
+		return d.Cards, nil
+	}
+	cards, exists := d.Piles[name]
+	if !exists {
+		return nil, ErrPileNotFound
+	}
+	return cards, nil
+}
+
+func (d *Deck) setSource(name string, cards []Card) {
+	if name == "" {
+		d.Cards = cards
+		d.Remaining = len(d.Cards)
+		return
+	}
+	if len(cards) == 0 {
+		delete(d.Piles, name)
+		return
+	}
+	if d.Piles == nil {
+		d.Piles = make(map[string][]Card)
+	}
+	d.Piles[name] = cards
+}
+
+// MoveToPile moves cards onto the top of pile from the pile named from, or from
+// the draw pile when from is empty. Either the top count cards move or, when
+// codes is not empty, exactly those cards.
+func (d *Deck) MoveToPile(pile string, from string, count int, codes []Card) ([]Card, error) {
+	if d.Closed {
+		return nil, ErrDeckClosed
+	}
+	if pile == from {
+		return nil, errors.New("cannot move cards onto the pile they come from")
+	}
+	cards, err := d.source(from)
+	if err != nil {
+		return nil, err
+	}
+	var moved []Card
+	if len(codes) > 0 {
+		cards, moved, err = takeCodes(cards, codes)
+	} else {
+		cards, moved, err = popTop(cards, count)
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.setSource(from, cards)
+	target, _ := d.source(pile)
+	d.setSource(pile, append(append([]Card(nil), target...), moved...))
+	return moved, nil
+}
+
+// ShufflePile moves every card of pile back into the draw pile and shuffles it.
+func (d *Deck) ShufflePile(pile string) error {
+	if d.Closed {
+		return ErrDeckClosed
+	}
+	cards, err := d.source(pile)
+	if err != nil {
+		return err
+	}
+	d.Cards = append(d.Cards, cards...)
+	delete(d.Piles, pile)
+	return d.Reshuffle(ReshuffleSecure, 0, false)
+}
+
+func MoveCards(store DeckStore, deckId string, pile string, from string, count int, codes []Card) ([]Card, error) {
+	if len(codes) == 0 && count < 1 {
+		return nil, fmt.Errorf("%w: must be greater than zero", ErrInvalidCount)
+	}
+	var moved []Card
+	_, err := store.UpdateDeck(deckId, func(deck *Deck) error {
+		var err error
+		moved, err = deck.MoveToPile(pile, from, count, codes)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+func ListPile(store DeckStore, deckId string, pile string) ([]Card, error) {
+	deck, err := OpenDeck(store, deckId)
+	if err != nil {
+		return nil, err
+	}
+	return deck.source(pile)
+}
+
+func ShufflePileIntoDeck(store DeckStore, deckId string, pile string) (Deck, error) {
+	deck, err := store.UpdateDeck(deckId, func(deck *Deck) error {
+		return deck.ShufflePile(pile)
+	})
+	deck.Cards = nil
+	return deck, err
+}
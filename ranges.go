@@ -0,0 +1,60 @@
+package main
+
+import (
+	"card-game/hand"
+	"math"
+	"strings"
+)
+
+// RangeCombo is one pair of hole cards in a range.
+type RangeCombo struct {
+	Cards  []Card  `json:"cards"`
+	Weight float64 `json:"weight"`
+}
+
+// RangeResult is a range expanded into the combos it still holds.
+type RangeResult struct {
+	Combos []RangeCombo `json:"combos"`
+	// Count is how many combos the range holds and Weighted the same count
+	// with each combo counted by its weight.
+	Count    int     `json:"count"`
+	Weighted float64 `json:"weighted"`
+	// Blocked is how many combos were left out for holding a dead card.
+	Blocked int `json:"blocked"`
+	// Percent is the share of all starting hands the range holds, by weight.
+	Percent float64 `json:"percent"`
+}
+
+// ExpandRange expands range notation, see hand.ParseRange, leaving out combos
+// that hold any of the dead card codes.
+func ExpandRange(notation string, dead []string) (RangeResult, error) {
+	r, err := hand.ParseRange(notation)
+	if err != nil {
+		return RangeResult{}, err
+	}
+	var deadCards []hand.Card
+	if len(dead) > 0 {
+		codes, err := ParseCardCodes(strings.Join(dead, ","), DeckOptions{}.CardCodes())
+		if err != nil {
+			return RangeResult{}, err
+		}
+		if deadCards, err = hand.ParseCards(codes...); err != nil {
+			return RangeResult{}, err
+		}
+	}
+	live := r.Without(deadCards...)
+
+	result := RangeResult{
+		Combos:   []RangeCombo{},
+		Count:    len(live),
+		Weighted: math.Round(live.Weighted()*1000) / 1000,
+		Blocked:  len(r) - len(live),
+		Percent:  percent(live.Weighted(), hand.StartingHands),
+	}
+	for _, combo := range live {
+		first, _ := ParseCard(combo.Cards[0].String())
+		second, _ := ParseCard(combo.Cards[1].String())
+		result.Combos = append(result.Combos, RangeCombo{Cards: []Card{first, second}, Weight: combo.Weight})
+	}
+	return result, nil
+}
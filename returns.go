@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Where returned cards go back into the deck.
+const (
+	ReturnTop     = "top"
+	ReturnBottom  = "bottom"
+	ReturnRandom  = "random"
+	ReturnShuffle = "shuffle"
+)
+
+func validateReturnPosition(position string) error {
+	switch position {
+	case ReturnTop, ReturnBottom, ReturnRandom, ReturnShuffle:
+		return nil
+	}
+	return fmt.Errorf("unknown position %q, expected one of %q, %q, %q or %q",
+		position, ReturnTop, ReturnBottom, ReturnRandom, ReturnShuffle)
+}
+
+// ReturnCards puts drawn cards back into the deck at position. Cards that never
+// belonged to the deck, or are in it already, are rejected with a
+// *CardCodeError and nothing is returned. When a shoe has several copies of a
+// code out, the most recently drawn copy goes back first.
+func (d *Deck) ReturnCards(cards []Card, position string) error {
+	if d.Closed {
+		return ErrDeckClosed
+	}
+	if err := validateReturnPosition(position); err != nil {
+		return err
+	}
+
+	belongs := d.belongs()
+	drawn := append([]Card(nil), d.Drawn...)
+	var returned []Card
+	codeErr := &CardCodeError{}
+	for _, card := range cards {
+		if !belongs[card.Code] {
+			codeErr.NotInDeck = append(codeErr.NotInDeck, card.Code)
+			continue
+		}
+		found := false
+		for i := len(drawn) - 1; i >= 0; i-- {
+			if drawn[i].Canonical().Code == card.Code {
+				returned = append(returned, drawn[i])
+				drawn = append(drawn[:i], drawn[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			codeErr.NotDrawn = append(codeErr.NotDrawn, card.Code)
+		}
+	}
+	if len(codeErr.NotInDeck) > 0 || len(codeErr.NotDrawn) > 0 {
+		return codeErr
+	}
+
+	switch position {
+	case ReturnTop:
+		d.Cards = append(d.Cards, returned...)
+	case ReturnBottom:
+		d.Cards = append(append([]Card(nil), returned...), d.Cards...)
+	case ReturnRandom:
+		for _, card := range returned {
+			i := secureIntn(len(d.Cards) + 1)
+			d.Cards = append(d.Cards[:i], append([]Card{card}, d.Cards[i:]...)...)
+		}
+	case ReturnShuffle:
+		d.Cards = append(d.Cards, returned...)
+		secureShuffle(len(d.Cards), func(i, j int) {
+			d.Cards[i], d.Cards[j] = d.Cards[j], d.Cards[i]
+		})
+		d.Shuffled = true
+	}
+	d.Drawn = drawn
+	d.Remaining = len(d.Cards)
+	// a deck shuffled by the return is committed to like any reshuffled one
+	if d.Commitment != "" || position == ReturnShuffle {
+		d.recommit("return")
+	}
+	return nil
+}
+
+func ReturnCards(store DeckStore, deckId string, cards []Card, position string) (Deck, error) {
+	if len(cards) == 0 {
+		return Deck{}, errors.New("no cards to return")
+	}
+	if position == "" {
+		position = ReturnTop
+	}
+	deck, err := store.UpdateDeck(deckId, func(deck *Deck) error {
+		return deck.ReturnCards(cards, position)
+	})
+	deck.Cards = nil
+	return deck, err
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+const (
+	// ShuffleModeSeeded shuffles with math/rand from the deck's seed, so the
+	// order can be replayed.
+	ShuffleModeSeeded = "seeded"
+	// ShuffleModeSecure shuffles with crypto/rand. The order is unpredictable
+	// and cannot be replayed.
+	ShuffleModeSecure = "secure"
+)
+
+func validateShuffleMode(mode string) error {
+	switch mode {
+	case "", ShuffleModeSeeded, ShuffleModeSecure:
+		return nil
+	}
+	return fmt.Errorf("unknown shuffle mode %q, expected %q or %q", mode, ShuffleModeSeeded, ShuffleModeSecure)
+}
+
+// secureShuffle is a Fisher-Yates shuffle drawing every index from crypto/rand.
+func secureShuffle(n int, swap func(i, j int)) {
+	for i := n - 1; i > 0; i-- {
+		swap(i, secureIntn(i+1))
+	}
+}
+
+// secureIntn returns a uniform random int in [0, n) from crypto/rand. rand.Int
+// rejects out of range samples, so no value is favoured by modulo bias.
+func secureIntn(n int) int {
+	i, err := cryptorand.Int(cryptorand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(err)
+	}
+	return int(i.Int64())
+}
+
+// NewSeed returns a fresh random seed for decks shuffled without one.
+func NewSeed() string {
+	var b [8]byte
+	if _, err := cryptorand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return strconv.FormatUint(binary.BigEndian.Uint64(b[:]), 10)
+}
+
+// HashSeed is what a deck exposes instead of its seed, so the order cannot be
+// predicted from the API while the seed can still be checked later.
+func HashSeed(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:])
+}
+
+// seedSource turns any seed string into the int64 a math/rand source needs.
+func seedSource(seed string) int64 {
+	sum := sha256.Sum256([]byte(seed))
+	return int64(binary.BigEndian.Uint64(sum[:8]))
+}
+
+// Ways ShuffleDeck can reshuffle an existing deck.
+const (
+	ReshuffleSecure = "secure"
+	ReshuffleRiffle = "riffle"
+)
+
+// DefaultRiffles is enough riffles to mix a 52-card deck well.
+const DefaultRiffles = 7
+
+// MaxRiffles bounds how many riffles one request may ask for.
+const MaxRiffles = 100
+
+// riffle simulates one riffle shuffle with the Gilbert-Shannon-Reeds model:
+// the deck is cut binomially into two packets, then cards drop one at a time
+// from either packet with probability proportional to its size.
+func riffle(cards []Card) []Card {
+	cut := 0
+	for range cards {
+		cut += secureIntn(2)
+	}
+	// the top of the deck is the end of the slice
+	left, right := cards[len(cards)-cut:], cards[:len(cards)-cut]
+	shuffled := make([]Card, 0, len(cards))
+	for len(left) > 0 || len(right) > 0 {
+		if secureIntn(len(left)+len(right)) < len(left) {
+			shuffled = append(shuffled, left[0])
+			left = left[1:]
+		} else {
+			shuffled = append(shuffled, right[0])
+			right = right[1:]
+		}
+	}
+	return shuffled
+}
+
+func validateReshuffle(mode string, riffles int) error {
+	switch mode {
+	case ReshuffleSecure:
+	case ReshuffleRiffle:
+		if riffles < 1 || riffles > MaxRiffles {
+			return fmt.Errorf("riffles must be between 1 and %d", MaxRiffles)
+		}
+	default:
+		return fmt.Errorf("unknown shuffle mode %q, expected %q or %q", mode, ReshuffleSecure, ReshuffleRiffle)
+	}
+	return nil
+}
+
+// Reshuffle shuffles the draw pile again, after first moving every pile back
+// into it when includePiles is set.
+func (d *Deck) Reshuffle(mode string, riffles int, includePiles bool) error {
+	if d.Closed {
+		return ErrDeckClosed
+	}
+	if err := validateReshuffle(mode, riffles); err != nil {
+		return err
+	}
+
+	if includePiles {
+		for name, pile := range d.Piles {
+			d.Cards = append(d.Cards, pile...)
+			delete(d.Piles, name)
+		}
+	}
+	if mode == ReshuffleRiffle {
+		for i := 0; i < riffles; i++ {
+			d.Cards = riffle(d.Cards)
+		}
+	} else {
+		secureShuffle(len(d.Cards), func(i, j int) {
+			d.Cards[i], d.Cards[j] = d.Cards[j], d.Cards[i]
+		})
+	}
+	d.Shuffled = true
+	d.Remaining = len(d.Cards)
+	d.recommit("reshuffle")
+	return nil
+}
+
+// Cut lifts the top at cards and puts them underneath the rest.
+func (d *Deck) Cut(at int) error {
+	if d.Closed {
+		return ErrDeckClosed
+	}
+	if at < 1 || at >= len(d.Cards) {
+		return fmt.Errorf("cut must leave cards in both packets, at must be between 1 and %d", len(d.Cards)-1)
+	}
+	top := d.Cards[len(d.Cards)-at:]
+	d.Cards = append(append([]Card(nil), top...), d.Cards[:len(d.Cards)-at]...)
+	if d.Commitment != "" {
+		d.recommit("cut")
+	}
+	return nil
+}
+
+func ShuffleDeck(store DeckStore, deckId string, mode string, riffles int, includePiles bool) (Deck, error) {
+	deck, err := store.UpdateDeck(deckId, func(deck *Deck) error {
+		return deck.Reshuffle(mode, riffles, includePiles)
+	})
+	deck.Cards = nil
+	return deck, err
+}
+
+func CutDeck(store DeckStore, deckId string, at int) (Deck, error) {
+	deck, err := store.UpdateDeck(deckId, func(deck *Deck) error {
+		return deck.Cut(at)
+	})
+	deck.Cards = nil
+	return deck, err
+}
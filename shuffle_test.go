@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// chiSquare returns the chi-square statistic of observed counts against a
+// uniform expectation over len(observed) buckets.
+func chiSquare(observed []int, total int) float64 {
+	expected := float64(total) / float64(len(observed))
+	var statistic float64
+	for _, o := range observed {
+		diff := float64(o) - expected
+		statistic += diff * diff / expected
+	}
+	return statistic
+}
+
+func TestSecureShuffle(t *testing.T) {
+	t.Run("Every permutation is equally likely", func(t *testing.T) {
+		// 4 items have 24 permutations, so 23 degrees of freedom. 49.73 is the
+		// chi-square critical value at p = 0.001.
+		trials := 24000
+		counts := make(map[string]int)
+		for i := 0; i < trials; i++ {
+			items := []int{0, 1, 2, 3}
+			secureShuffle(len(items), func(i, j int) {
+				items[i], items[j] = items[j], items[i]
+			})
+			counts[fmt.Sprint(items)]++
+		}
+		if len(counts) != 24 {
+			t.Fatalf("Every permutation should occur, expected: %v, actual: %v", 24, len(counts))
+		}
+		var observed []int
+		for _, c := range counts {
+			observed = append(observed, c)
+		}
+		if statistic := chiSquare(observed, trials); statistic > 49.73 {
+			t.Errorf("Permutations are not uniform, chi-square: %v", statistic)
+		}
+	})
+	t.Run("Every card is equally likely on top of a secure deck", func(t *testing.T) {
+		// 52 positions give 51 degrees of freedom. 87.97 is the chi-square
+		// critical value at p = 0.001.
+		trials := 5200
+		positions := make(map[string]int)
+		for i := 0; i < trials; i++ {
+			deck := Deck{ShuffleMode: ShuffleModeSecure}
+			deck.GenerateCards(true)
+			positions[deck.Cards[len(deck.Cards)-1].Code]++
+		}
+		if len(positions) != 52 {
+			t.Fatalf("Every card should reach the top, expected: %v, actual: %v", 52, len(positions))
+		}
+		var observed []int
+		for _, c := range positions {
+			observed = append(observed, c)
+		}
+		if statistic := chiSquare(observed, trials); statistic > 87.97 {
+			t.Errorf("Top cards are not uniform, chi-square: %v", statistic)
+		}
+	})
+	t.Run("Secure deck records no seed", func(t *testing.T) {
+		deck := Deck{ShuffleMode: ShuffleModeSecure}
+		deck.GenerateCards(true)
+		if deck.Seed != "" || deck.SeedHash != "" {
+			t.Errorf("Secure deck should not record a seed, seed: %v, seed hash: %v", deck.Seed, deck.SeedHash)
+		}
+		if _, err := deck.OriginalCards(); err == nil {
+			t.Error("An error is expected to return")
+		}
+	})
+}
+
+func TestReshuffle(t *testing.T) {
+	t.Run("One riffle leaves at most two rising sequences", func(t *testing.T) {
+		for trial := 0; trial < 100; trial++ {
+			deck := Deck{}
+			deck.GenerateCards(false)
+			position := make(map[Card]int)
+			for i, card := range deck.Cards {
+				position[card] = i
+			}
+			shuffled := riffle(deck.Cards)
+			if len(shuffled) != 52 {
+				t.Fatalf("Riffle should keep every card, expected: %v, actual: %v", 52, len(shuffled))
+			}
+			// a rising sequence ends wherever card i+1 lies before card i
+			index := make([]int, len(shuffled))
+			for i, card := range shuffled {
+				index[position[card]] = i
+			}
+			sequences := 1
+			for i := 0; i+1 < len(index); i++ {
+				if index[i+1] < index[i] {
+					sequences++
+				}
+			}
+			if sequences > 2 {
+				t.Fatalf("A single riffle should leave at most 2 rising sequences, actual: %v", sequences)
+			}
+		}
+	})
+	t.Run("Reshuffle including piles", func(t *testing.T) {
+		deck := Deck{}
+		deck.GenerateCards(false)
+		if _, err := deck.MoveToPile("discard", "", 10, nil); err != nil {
+			t.Fatalf("Failed to move cards to a pile: %v", err)
+		}
+		if err := deck.Reshuffle(ReshuffleRiffle, DefaultRiffles, true); err != nil {
+			t.Errorf("Failed to reshuffle: %v", err)
+		}
+		if len(deck.Cards) != 52 || deck.Remaining != 52 || len(deck.Piles) != 0 || !deck.Shuffled {
+			t.Errorf("Piles should be shuffled back in, remaining: %v, piles: %v", deck.Remaining, deck.Piles)
+		}
+		if !isShuffled(deck.Cards) {
+			t.Error("Deck should be shuffled, please check the sequence of the cards.")
+		}
+	})
+	t.Run("Cut moves the top packet underneath", func(t *testing.T) {
+		deck := Deck{}
+		deck.GenerateCards(false)
+		if err := deck.Cut(2); err != nil {
+			t.Errorf("Failed to cut: %v", err)
+		}
+		top := deck.Cards[len(deck.Cards)-1].Code
+		bottom := deck.Cards[0].Code + "," + deck.Cards[1].Code
+		if top != "JH" || bottom != "QH,KH" {
+			t.Errorf("Cut is incorrect, top: %v, bottom: %v", top, bottom)
+		}
+		for _, at := range []int{0, 52} {
+			if err := deck.Cut(at); err == nil {
+				t.Errorf("An error is expected to return for a cut at %v", at)
+			}
+		}
+	})
+	t.Run("Reorders commit to the new order", func(t *testing.T) {
+		store := NewMemoryStore()
+		deck, err := CreateDeck(store, DeckOptions{Shuffled: true})
+		if err != nil {
+			t.Fatalf("Failed to create deck: %v", err)
+		}
+		first, _ := DrawCards(store, deck.DeckId, DrawOptions{Count: 5})
+		if _, err = ShuffleDeck(store, deck.DeckId, ReshuffleSecure, 0, false); err != nil {
+			t.Fatalf("Failed to reshuffle: %v", err)
+		}
+		cut, err := CutDeck(store, deck.DeckId, 10)
+		if err != nil {
+			t.Fatalf("Failed to cut: %v", err)
+		}
+		rest, _ := DrawCards(store, deck.DeckId, DrawOptions{Count: 47})
+
+		reveal, err := RevealDeck(store, deck.DeckId)
+		if err != nil {
+			t.Fatalf("Failed to reveal: %v", err)
+		}
+		if reveal.Commitment != cut.Commitment || !VerifyCommitment(reveal.Commitment, reveal.Salt, reveal.Order) {
+			t.Error("Revealed order should match the commitment made after the cut.")
+		}
+		if fmt.Sprint(reveal.Order) != fmt.Sprint(cardCodes(rest)) {
+			t.Errorf("Cards dealt after the cut should follow the revealed order, dealt: %v, order: %v", cardCodes(rest), reveal.Order)
+		}
+		if len(reveal.Past) != 2 || reveal.Past[0].Commitment != deck.Commitment || reveal.Past[1].ReplacedBy != "cut" {
+			t.Fatalf("Replaced commitments should be revealed oldest first, actual: %v", reveal.Past)
+		}
+		for _, past := range reveal.Past {
+			if !VerifyCommitment(past.Commitment, past.Salt, past.Order) {
+				t.Errorf("Replaced commitment %v should verify.", past.Commitment)
+			}
+		}
+		if fmt.Sprint(reveal.Past[0].Order[:5]) != fmt.Sprint(cardCodes(first)) {
+			t.Errorf("Cards dealt first should follow the first order, dealt: %v, order: %v", cardCodes(first), reveal.Past[0].Order[:5])
+		}
+	})
+}
+
+func cardCodes(cards []Card) []string {
+	var codes []string
+	for _, card := range cards {
+		codes = append(codes, card.Code)
+	}
+	return codes
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var ErrDeckNotFound = errors.New("deck not found")
+var ErrConcurrentUpdate = errors.New("deck is being modified concurrently, please retry")
+var ErrStoreUnavailable = errors.New("deck store is unavailable")
+
+// maxUpdateAttempts bounds how often a store retries an update that lost a race.
+const maxUpdateAttempts = 100
+
+// DeckStore persists decks and hands out the cards remaining in them. It also
+// keeps the tables dealt from those decks.
+type DeckStore interface {
+	InsertDeck(deck Deck) (interface{}, error)
+	// GetDeck reports an expired deck as ErrDeckNotFound, even if the store
+	// has not removed it yet.
+	GetDeck(deckId string) (Deck, error)
+	// UpdateDeck atomically applies update to the stored deck, with
+	// applyUpdate, and returns the result. If update returns an error the deck
+	// is left untouched.
+	UpdateDeck(deckId string, update func(deck *Deck) error) (Deck, error)
+	DrawCardsFromDeck(deckId string, count int) ([]Card, error)
+	// ListDecks returns up to limit decks matching filter, oldest first and by
+	// id among decks created at the same time, starting after the cursor when
+	// it is not nil. Expired decks are left out, cards may be.
+	ListDecks(filter DeckFilter, after *DeckCursor, limit int) ([]Deck, error)
+	DeleteDeck(deckId string) error
+	// ReserveResponse stores response unless an unexpired response with the
+	// same Id exists, in which case that one is returned and reserved is false.
+	ReserveResponse(response StoredResponse) (stored StoredResponse, reserved bool, err error)
+	SaveResponse(response StoredResponse) error
+	DeleteResponse(id string) error
+	InsertTable(table Table) error
+	GetTable(tableId string) (Table, error)
+	// UpdateTable atomically applies update to the stored table, with
+	// applyTableUpdate, and returns the result. If update returns an error the
+	// table is left untouched.
+	UpdateTable(tableId string, update func(table *Table) error) (Table, error)
+	Close() error
+}
+
+// NewDeckStore builds the store named by kind, "mongo" (the default) or "memory".
+func NewDeckStore(kind string) (DeckStore, error) {
+	switch kind {
+	case "", "mongo":
+		store, err := NewMongoStore(
+			os.Getenv("MONGO_CONNECTION_STRING"),
+			os.Getenv("DB_NAME"),
+			os.Getenv("POKER_COLLECTION_NAME"),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err = store.EnsureIndexes(); err != nil {
+			_ = store.Close()
+			return nil, err
+		}
+		return store, nil
+	case "memory":
+		return NewMemoryStore(), nil
+	}
+	return nil, fmt.Errorf("unknown deck store %q", kind)
+}
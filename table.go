@@ -0,0 +1,482 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var ErrTableNotFound = errors.New("table not found")
+var ErrTableAdvanced = errors.New("table was advanced by another request, please retry")
+var ErrNotEnoughPlayers = errors.New("a hand needs at least two seated players")
+var ErrHandInProgress = errors.New("a hand is in progress")
+var ErrSeatTaken = errors.New("seat is taken")
+var ErrTableDeck = errors.New("deck is dealt by a table and can only be used through it")
+var ErrSeatTokenRequired = errors.New("a seat token is required")
+var ErrSeatTokenInvalid = errors.New("seat token does not belong to the seat")
+
+// Phases of a Texas Hold'em hand. A table is waiting before its first hand
+// and goes back to preflop from showdown when the next hand starts.
+const (
+	PhaseWaiting  = "waiting"
+	PhasePreflop  = "preflop"
+	PhaseFlop     = "flop"
+	PhaseTurn     = "turn"
+	PhaseRiver    = "river"
+	PhaseShowdown = "showdown"
+)
+
+// MaxSeats is the most seats a table can have.
+const MaxSeats = 10
+
+const maxPlayerNameLength = 64
+
+// BurnPile is the deck pile that collects the cards burnt before each street.
+const BurnPile = "burn"
+
+// HandDeckTTL is how long the deck of a hand is kept, long enough to finish
+// the hand and reveal the deck afterwards.
+const HandDeckTTL = 24 * time.Hour
+
+// streets maps the phase a table is in to the street dealt next and how many
+// board cards it adds. The street's cards go to a deck pile of the same name.
+var streets = map[string]struct {
+	next  string
+	cards int
+}{
+	PhasePreflop: {PhaseFlop, 3},
+	PhaseFlop:    {PhaseTurn, 1},
+	PhaseTurn:    {PhaseRiver, 1},
+}
+
+// Seat is a place at a table, empty when it has no Player.
+type Seat struct {
+	Number int    `json:"number" bson:"number"`
+	Player string `json:"player,omitempty" bson:"player,omitempty"`
+	// Cards are the hole cards dealt to the seat in the current hand.
+	Cards []Card `json:"cards,omitempty" bson:"cards,omitempty"`
+	// Token lets the player see their own hole cards. It is only set in the
+	// response that seats the player, the table keeps just its TokenHash.
+	Token     string `json:"token,omitempty" bson:"-"`
+	TokenHash string `json:"-" bson:"token_hash,omitempty"`
+}
+
+// newSeatToken returns a fresh seat token and the hash a seat keeps of it.
+func newSeatToken() (string, string) {
+	token := newSalt()
+	return token, hashSeatToken(token)
+}
+
+func hashSeatToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Table is a Texas Hold'em table. Every hand is dealt from a fresh shuffled
+// deck, tagged "table:<table id>", whose piles hold each seat's hole cards,
+// the burnt cards and the cards of each street.
+type Table struct {
+	TableId string `json:"table_id" bson:"_id"`
+	Seats   []Seat `json:"seats" bson:"seats"`
+	// Button is the seat number of the dealer button, 0 before the first hand.
+	Button int    `json:"button" bson:"button"`
+	Phase  string `json:"phase" bson:"phase"`
+	// Hand counts the hands started at the table.
+	Hand int `json:"hand" bson:"hand"`
+	// DeckId is the deck the current hand is dealt from.
+	DeckId    string    `json:"deck_id,omitempty" bson:"deck_id,omitempty"`
+	Board     []Card    `json:"board,omitempty" bson:"board,omitempty"`
+	Showdown  *Showdown `json:"showdown,omitempty" bson:"showdown,omitempty"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+	Version   int64     `json:"-" bson:"version"`
+}
+
+// TableOptions describes the table CreateTable should set up.
+type TableOptions struct {
+	// Seats is how many seats the table has, zero means one per player.
+	Seats int
+	// Players sit down in the first seats, in order.
+	Players []string
+}
+
+func (opts TableOptions) Validate() error {
+	seats := opts.Seats
+	if seats == 0 {
+		seats = len(opts.Players)
+	}
+	if seats < 2 || seats > MaxSeats {
+		return fmt.Errorf("a table has between 2 and %d seats", MaxSeats)
+	}
+	if len(opts.Players) > seats {
+		return fmt.Errorf("%d players do not fit in %d seats", len(opts.Players), seats)
+	}
+	seen := make(map[string]bool)
+	for _, player := range opts.Players {
+		if err := validatePlayerName(player); err != nil {
+			return err
+		}
+		if seen[player] {
+			return fmt.Errorf("player %q is listed more than once", player)
+		}
+		seen[player] = true
+	}
+	return nil
+}
+
+func validatePlayerName(player string) error {
+	if player == "" || len(player) > maxPlayerNameLength {
+		return fmt.Errorf("player names must have 1 to %d characters", maxPlayerNameLength)
+	}
+	return nil
+}
+
+// Conceal hides the hole cards of every seat until the showdown.
+func (t *Table) Conceal() {
+	if t.Phase == PhaseShowdown {
+		return
+	}
+	for i := range t.Seats {
+		t.Seats[i].Cards = nil
+	}
+}
+
+// inHand reports whether the table is between the deal and the showdown.
+func (t Table) inHand() bool {
+	return t.Phase != PhaseWaiting && t.Phase != PhaseShowdown
+}
+
+// seat returns the index of the seat numbered number.
+func (t Table) seat(number int) (int, error) {
+	if number < 1 || number > len(t.Seats) {
+		return 0, fmt.Errorf("seat must be between 1 and %d", len(t.Seats))
+	}
+	return number - 1, nil
+}
+
+// dealOrder lists the occupied seats clockwise starting left of button.
+func (t Table) dealOrder(button int) []int {
+	var order []int
+	for i := 0; i < len(t.Seats); i++ {
+		index := (button + i) % len(t.Seats)
+		if t.Seats[index].Player != "" {
+			order = append(order, index)
+		}
+	}
+	return order
+}
+
+// applyTableUpdate runs update on table and stamps the table as updated.
+func applyTableUpdate(table *Table, update func(table *Table) error) error {
+	if err := update(table); err != nil {
+		return err
+	}
+	table.UpdatedAt = timestamp()
+	return nil
+}
+
+func CreateTable(store DeckStore, opts TableOptions) (Table, error) {
+	if err := opts.Validate(); err != nil {
+		return Table{}, err
+	}
+	seats := opts.Seats
+	if seats == 0 {
+		seats = len(opts.Players)
+	}
+	table := Table{
+		TableId:   uuid.NewString(),
+		Phase:     PhaseWaiting,
+		CreatedAt: timestamp(),
+	}
+	table.UpdatedAt = table.CreatedAt
+	for i := 0; i < seats; i++ {
+		table.Seats = append(table.Seats, Seat{Number: i + 1})
+	}
+	tokens := make([]string, len(opts.Players))
+	for i, player := range opts.Players {
+		table.Seats[i].Player = player
+		tokens[i], table.Seats[i].TokenHash = newSeatToken()
+	}
+	if err := store.InsertTable(table); err != nil {
+		return Table{}, err
+	}
+	// the tokens are handed out once and never stored
+	for i, token := range tokens {
+		table.Seats[i].Token = token
+	}
+	return table, nil
+}
+
+func OpenTable(store DeckStore, tableId string) (Table, error) {
+	return store.GetTable(tableId)
+}
+
+// SitDown seats player in the empty seat numbered seat, between hands. The
+// seat in the returned table carries the player's seat token.
+func SitDown(store DeckStore, tableId string, seat int, player string) (Table, error) {
+	if err := validatePlayerName(player); err != nil {
+		return Table{}, err
+	}
+	token, tokenHash := newSeatToken()
+	table, err := store.UpdateTable(tableId, func(table *Table) error {
+		i, err := table.seat(seat)
+		if err != nil {
+			return err
+		}
+		if table.inHand() {
+			return ErrHandInProgress
+		}
+		if table.Seats[i].Player != "" {
+			return ErrSeatTaken
+		}
+		for _, s := range table.Seats {
+			if s.Player == player {
+				return fmt.Errorf("player %q already sits in seat %d", player, s.Number)
+			}
+		}
+		table.Seats[i] = Seat{Number: seat, Player: player, TokenHash: tokenHash}
+		return nil
+	})
+	if err != nil {
+		return Table{}, err
+	}
+	table.Seats[seat-1].Token = token
+	return table, nil
+}
+
+// SeatCards returns the seat numbered seat, with its hole cards, to the player
+// holding token, the seat token they were given when they sat down.
+func SeatCards(store DeckStore, tableId string, seat int, token string) (Seat, error) {
+	if token == "" {
+		return Seat{}, ErrSeatTokenRequired
+	}
+	table, err := store.GetTable(tableId)
+	if err != nil {
+		return Seat{}, err
+	}
+	i, err := table.seat(seat)
+	if err != nil {
+		return Seat{}, err
+	}
+	s := table.Seats[i]
+	if s.TokenHash == "" || subtle.ConstantTimeCompare([]byte(s.TokenHash), []byte(hashSeatToken(token))) != 1 {
+		return Seat{}, ErrSeatTokenInvalid
+	}
+	return s, nil
+}
+
+// StandUp empties the seat numbered seat, between hands.
+func StandUp(store DeckStore, tableId string, seat int) (Table, error) {
+	return store.UpdateTable(tableId, func(table *Table) error {
+		i, err := table.seat(seat)
+		if err != nil {
+			return err
+		}
+		if table.inHand() {
+			return ErrHandInProgress
+		}
+		table.Seats[i] = Seat{Number: seat}
+		return nil
+	})
+}
+
+// AdvanceTable moves the table to its next phase: it starts a hand, deals the
+// flop, the turn or the river, or shows the hands down.
+//
+// The cards of every step are drawn into deck piles named after it, so a
+// step that failed half way, say after drawing the flop but before saving
+// the table, reuses the cards already drawn when it is retried. Two requests
+// advancing the same table at once cannot both succeed, the loser gets
+// ErrTableAdvanced.
+func AdvanceTable(store DeckStore, tableId string) (Table, error) {
+	table, err := store.GetTable(tableId)
+	if err != nil {
+		return Table{}, err
+	}
+	switch table.Phase {
+	case PhaseWaiting, PhaseShowdown:
+		return startHand(store, table)
+	case PhaseRiver:
+		return showDown(store, table)
+	}
+	return dealStreet(store, table)
+}
+
+// advanceFrom applies update to the table only if no one advanced it since it
+// was read as table.
+func advanceFrom(store DeckStore, table Table, update func(table *Table) error) (Table, error) {
+	return store.UpdateTable(table.TableId, func(current *Table) error {
+		if current.Phase != table.Phase || current.Hand != table.Hand {
+			return ErrTableAdvanced
+		}
+		return update(current)
+	})
+}
+
+func seatPile(seat Seat) string {
+	return "seat-" + strconv.Itoa(seat.Number)
+}
+
+// startHand moves the button to the next seated player, shuffles a new deck
+// and deals two hole cards to every seated player, one at a time starting
+// left of the button.
+func startHand(store DeckStore, table Table) (Table, error) {
+	if len(table.dealOrder(0)) < 2 {
+		return Table{}, ErrNotEnoughPlayers
+	}
+	// seat numbers are one more than indexes, so the button's number is the
+	// index of the seat to its left
+	button := table.Seats[table.dealOrder(table.Button)[0]].Number
+	order := table.dealOrder(button)
+
+	deck, err := CreateDeck(store, DeckOptions{
+		Shuffled: true,
+		Tags:     []string{"table:" + table.TableId},
+		Labels:   map[string]string{"hand": strconv.Itoa(table.Hand + 1)},
+		TTL:      HandDeckTTL,
+		TableId:  table.TableId,
+	})
+	if err != nil {
+		return Table{}, err
+	}
+	deck, err = store.UpdateDeck(deck.DeckId, func(deck *Deck) error {
+		for round := 0; round < 2; round++ {
+			for _, i := range order {
+				if _, err := deck.Draw(DrawOptions{Count: 1, Pile: seatPile(table.Seats[i])}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Table{}, err
+	}
+
+	started, err := advanceFrom(store, table, func(t *Table) error {
+		for i, seat := range t.Seats {
+			if seat.Player != table.Seats[i].Player {
+				return ErrTableAdvanced
+			}
+		}
+		t.Phase = PhasePreflop
+		t.Hand++
+		t.Button = button
+		t.DeckId = deck.DeckId
+		t.Board = nil
+		t.Showdown = nil
+		for i := range t.Seats {
+			t.Seats[i].Cards = canonicalCards(deck.Piles[seatPile(t.Seats[i])])
+		}
+		return nil
+	})
+	if err != nil {
+		// nobody will deal from the deck, the table moved on without it
+		_ = DeleteDeck(store, deck.DeckId)
+		return Table{}, err
+	}
+	return started, nil
+}
+
+// dealStreet burns a card and deals the next street onto the board.
+func dealStreet(store DeckStore, table Table) (Table, error) {
+	street := streets[table.Phase]
+	deck, err := store.UpdateDeck(table.DeckId, func(deck *Deck) error {
+		if _, dealt := deck.Piles[street.next]; dealt {
+			return nil
+		}
+		if _, err := deck.Draw(DrawOptions{Count: 1, Pile: BurnPile}); err != nil {
+			return err
+		}
+		_, err := deck.Draw(DrawOptions{Count: street.cards, Pile: street.next})
+		return err
+	})
+	if errors.Is(err, ErrDeckNotFound) {
+		return callOffHand(store, table)
+	}
+	if err != nil {
+		return Table{}, err
+	}
+	return advanceFrom(store, table, func(t *Table) error {
+		t.Phase = street.next
+		t.Board = append(t.Board, canonicalCards(deck.Piles[street.next])...)
+		return nil
+	})
+}
+
+// showDown compares the hands of every player dealt in and closes the deck,
+// which lets anyone reveal it and check the hand was dealt fairly.
+func showDown(store DeckStore, table Table) (Table, error) {
+	var board []string
+	for _, card := range table.Board {
+		board = append(board, card.Code)
+	}
+	var players []ShowdownPlayer
+	for _, seat := range table.Seats {
+		if len(seat.Cards) == 0 {
+			continue
+		}
+		player := ShowdownPlayer{Id: seat.Player}
+		for _, card := range seat.Cards {
+			player.Cards = append(player.Cards, card.Code)
+		}
+		players = append(players, player)
+	}
+	result, err := CompareHands(board, players)
+	if err != nil {
+		return Table{}, err
+	}
+	if _, err = CloseDeck(store, table.DeckId); errors.Is(err, ErrDeckNotFound) {
+		return callOffHand(store, table)
+	} else if err != nil {
+		return Table{}, err
+	}
+	return advanceFrom(store, table, func(t *Table) error {
+		t.Phase = PhaseShowdown
+		t.Showdown = &result
+		return nil
+	})
+}
+
+// callOffHand puts the table back to waiting when the deck of its hand is
+// gone, say because it expired, so the next advance deals a new hand.
+func callOffHand(store DeckStore, table Table) (Table, error) {
+	return advanceFrom(store, table, func(t *Table) error {
+		t.Phase = PhaseWaiting
+		t.DeckId = ""
+		t.Board = nil
+		t.Showdown = nil
+		for i := range t.Seats {
+			t.Seats[i].Cards = nil
+		}
+		return nil
+	})
+}
+
+// GuardTableDecks rejects requests for a deck a table deals from, so nobody
+// but the table draws, reorders or removes its cards.
+func GuardTableDecks(store DeckStore) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		deck, err := store.GetDeck(context.Param("deckId"))
+		if err == nil && deck.TableId != "" {
+			respondError(context, http.StatusConflict, ErrTableDeck)
+			return
+		}
+		context.Next()
+	}
+}
+
+func canonicalCards(cards []Card) []Card {
+	var canonical []Card
+	for _, card := range cards {
+		canonical = append(canonical, card.Canonical())
+	}
+	return canonical
+}